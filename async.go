@@ -0,0 +1,132 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrShortRecord is returned by PendingScreen.Feed() when the client's
+// record ends before a complete response, such as a record without an AID.
+var ErrShortRecord = errors.New("go3270: client record ended unexpectedly")
+
+// PendingScreen is a screen sent with SendScreen() whose response has not
+// been received yet. It parses the response from data the caller reads from
+// the connection and passes to Feed(), so no goroutine is blocked reading
+// while the user looks at the screen.
+type PendingScreen struct {
+	screen Screen
+	fm     fieldmap
+	conn   Transport
+	opts   ScreenOpts
+	buf    []byte
+}
+
+// SendScreen writes the screen to the client as ShowScreenOpts() does, but
+// returns as soon as the screen is written instead of reading the user's
+// response. The caller reads the client's data from conn itself, for
+// example in a single loop that services many connections with a poller or
+// a shared reader, and passes what it reads to Feed() on the returned
+// PendingScreen until the response is complete. This allows an
+// event-driven server to handle many connections without a goroutine
+// blocked in a read for each one. If writing the screen fails, the error is
+// returned.
+//
+// Since go3270 doesn't read from conn, ScreenOpts.InputTimeout and
+// ScreenOpts.Registry don't apply: the caller decides how long to wait.
+func SendScreen(screen Screen, values map[string]string, conn Transport,
+	opts ScreenOpts) (*PendingScreen, error) {
+
+	screen = resolveRoundTrip(resolveFallbacks(screen, opts), values)
+
+	fm, err := writeScreen(screen, values, opts, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PendingScreen{screen: screen, fm: fm, conn: conn, opts: opts},
+		nil
+}
+
+// Feed adds data read from the client to the response. Feed never reads
+// from the connection. Until the client's record, which ends with a telnet
+// EOR, is complete, Feed returns done = false and keeps the data for the
+// next call. Once it is complete, Feed returns done = true with the
+// response, as ShowScreenOpts() would have returned it, and any data
+// received after the end of the record as rest, which belongs to whatever
+// the client sends next. Telnet option negotiation in the record is
+// answered by writing to the connection from Feed.
+func (p *PendingScreen) Feed(data []byte) (resp Response, done bool,
+	rest []byte, err error) {
+
+	p.buf = append(p.buf, data...)
+	end := recordEnd(p.buf)
+	if end < 0 {
+		return Response{}, false, nil, nil
+	}
+	record, rest := p.buf[:end], p.buf[end:]
+	p.buf = nil
+
+	// The record is complete, so the Clear and PA keys' short reads can
+	// always be read to the end of it.
+	opts := p.opts
+	opts.ConsumeShortReads = true
+	rc := &readConn{Transport: &recordTransport{bytes.NewReader(record),
+		p.conn}, prefix: opts.LogPrefix, conn: p.conn}
+	resp, err = readScreenResponse(p.screen, p.fm, rc, opts)
+	if err == io.EOF {
+		err = ErrShortRecord
+	}
+	return resp, true, rest, err
+}
+
+// recordEnd returns the index just after the telnet EOR that ends the first
+// record in data, or -1 if data doesn't contain a complete record. Escaped
+// 0xff bytes and telnet commands are skipped.
+func recordEnd(data []byte) int {
+	for i := 0; i < len(data); i++ {
+		if data[i] != iac {
+			continue
+		}
+		if i+1 >= len(data) {
+			return -1
+		}
+		switch cmd := data[i+1]; {
+		case cmd == eor:
+			return i + 2
+		case cmd == sb:
+			// Skip to the IAC SE that ends the subnegotiation
+			n := bytes.Index(data[i+2:], []byte{iac, se})
+			if n < 0 {
+				return -1
+			}
+			i += 2 + n + 1
+		case cmd >= will && cmd <= dont:
+			i += 2
+		default:
+			// An escaped 0xff or a two byte command
+			i++
+		}
+	}
+	return -1
+}
+
+// recordTransport is the Transport a PendingScreen parses a complete record
+// from. Reads come from the record, and writes go to the connection.
+type recordTransport struct {
+	*bytes.Reader
+	conn Transport
+}
+
+func (t *recordTransport) Write(b []byte) (int, error) {
+	return t.conn.Write(b)
+}
+
+func (t *recordTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
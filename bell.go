@@ -0,0 +1,15 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+// Bell sounds the client's audible alarm without changing anything on the
+// screen. It sends a Write command with only the alarm bit set in the WCC
+// and no orders, so the screen contents, the cursor position, and the state
+// of the keyboard are left alone; it does not wait for a response. This is
+// useful to alert the user to a change on an otherwise static screen, for
+// example after a StatusLine update.
+func Bell(conn Transport) error {
+	return writeUpdate(nil, nil, wccAlarm, defaultSize, conn)
+}
@@ -0,0 +1,262 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseBMS converts the DFHMDF field definitions in CICS BMS map source to
+// a Screen, to help migrate existing applications. It supports the common
+// subset of the DFHMDF operands:
+//
+//   - POS=(line,column), 1-based, or POS=number, the 0-based offset from
+//     the start of a 24x80 screen, is the position of the field attribute.
+//   - ATTRB: UNPROT makes the field writable, PROT makes it protected, and
+//     ASKIP (the default) makes it autoskip; BRT and DRK make it intense
+//     and hidden; NUM makes it numeric only. Other values, such as IC and
+//     FSET, are ignored.
+//   - COLOR (BLUE, RED, PINK, GREEN, TURQUOISE, YELLOW, NEUTRAL) and
+//     HILIGHT (BLINK, REVERSE, UNDERLINE) set the extended attributes.
+//   - INITIAL is the field's Content.
+//   - The field's label, if any, is its Name.
+//
+// All other macros (DFHMSD, DFHMDI, and so on) and operands, such as LENGTH
+// and PICIN, are ignored; in particular, maps positioned with the DFHMDI
+// LINE and COLUMN operands are placed at the top left of the screen. As in
+// BMS, each writable field must be followed by a field definition that ends
+// it. Comment lines (starting with "*") and statements continued with a
+// character in column 72 are handled.
+func ParseBMS(r io.Reader) (Screen, error) {
+	statements, err := bmsStatements(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var screen Screen
+	for _, stmt := range statements {
+		if stmt.macro != "DFHMDF" {
+			continue
+		}
+		fld, err := bmsField(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("go3270: BMS line %d: %v", stmt.line, err)
+		}
+		screen = append(screen, fld)
+	}
+	return screen, nil
+}
+
+// bmsStatement is an assembler macro statement from BMS source.
+type bmsStatement struct {
+	line     int // line number the statement starts on
+	label    string
+	macro    string
+	operands map[string]string
+}
+
+// bmsStatements reads the assembler statements in r, joining continuation
+// lines.
+func bmsStatements(r io.Reader) ([]bmsStatement, error) {
+	var result []bmsStatement
+	scanner := bufio.NewScanner(r)
+	lineNum, start := 0, 0
+	var text strings.Builder
+	continued := false
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if continued {
+			// Continuation lines resume in column 16
+			if len(line) > 15 {
+				line = line[15:]
+			} else {
+				line = ""
+			}
+		} else {
+			if strings.HasPrefix(line, "*") || strings.TrimSpace(line) == "" {
+				continue
+			}
+			start = lineNum
+		}
+
+		// Columns 73-80 are the sequence number; a non-blank column 72
+		// continues the statement on the next line.
+		continued = false
+		if len(line) >= 72 && line[71] != ' ' {
+			continued = true
+		}
+		if len(line) > 71 {
+			line = line[:71]
+		}
+		if continued && strings.Count(text.String()+line, "'")%2 == 0 {
+			// Outside of a quoted string, the operands continue from the
+			// comma that ends the line rather than from column 71.
+			line = strings.TrimRight(line, " ")
+		}
+		text.WriteString(line)
+		if continued {
+			continue
+		}
+
+		stmt, err := parseBMSStatement(text.String())
+		if err != nil {
+			return nil, fmt.Errorf("go3270: BMS line %d: %v", start, err)
+		}
+		stmt.line = start
+		result = append(result, stmt)
+		text.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseBMSStatement parses the label, macro name, and operands of an
+// assembler statement.
+func parseBMSStatement(text string) (bmsStatement, error) {
+	var stmt bmsStatement
+	if !strings.HasPrefix(text, " ") {
+		fields := strings.SplitN(text, " ", 2)
+		stmt.label = fields[0]
+		text = ""
+		if len(fields) > 1 {
+			text = fields[1]
+		}
+	}
+	text = strings.TrimLeft(text, " ")
+	fields := strings.SplitN(text, " ", 2)
+	stmt.macro = fields[0]
+	stmt.operands = make(map[string]string)
+	if len(fields) < 2 {
+		return stmt, nil
+	}
+
+	// The operands run until the first space outside of quotes; anything
+	// after that is a comment. Operands are separated by commas outside of
+	// quotes and parentheses.
+	operands := strings.TrimLeft(fields[1], " ")
+	var current strings.Builder
+	quoted, depth := false, 0
+	add := func() {
+		op := current.String()
+		current.Reset()
+		if op == "" {
+			return
+		}
+		key, value := op, ""
+		if eq := strings.Index(op, "="); eq >= 0 {
+			key, value = op[:eq], op[eq+1:]
+		}
+		stmt.operands[strings.ToUpper(key)] = value
+	}
+	for i := 0; i < len(operands); i++ {
+		c := operands[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			add()
+			continue
+		case c == ' ':
+			i = len(operands)
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if quoted {
+		return stmt, fmt.Errorf("unterminated quoted string")
+	}
+	add()
+	return stmt, nil
+}
+
+// bmsField converts a DFHMDF statement to a Field.
+func bmsField(stmt bmsStatement) (Field, error) {
+	fld := Field{Name: stmt.label, Autoskip: true}
+
+	pos, ok := stmt.operands["POS"]
+	if !ok {
+		return fld, fmt.Errorf("DFHMDF without POS")
+	}
+	if strings.HasPrefix(pos, "(") {
+		parts := strings.Split(strings.Trim(pos, "()"), ",")
+		if len(parts) != 2 {
+			return fld, fmt.Errorf("bad POS %q", pos)
+		}
+		line, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		col, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err1 != nil || err2 != nil {
+			return fld, fmt.Errorf("bad POS %q", pos)
+		}
+		fld.Row, fld.Col = line-1, col-1
+	} else {
+		offset, err := strconv.Atoi(pos)
+		if err != nil {
+			return fld, fmt.Errorf("bad POS %q", pos)
+		}
+		fld.Row, fld.Col = offset/80, offset%80
+	}
+
+	if attrb, ok := stmt.operands["ATTRB"]; ok {
+		for _, a := range strings.Split(strings.Trim(attrb, "()"), ",") {
+			switch strings.TrimSpace(a) {
+			case "PROT":
+				fld.Autoskip = false
+			case "UNPROT":
+				fld.Write = true
+			case "BRT":
+				fld.Intense = true
+			case "DRK":
+				fld.Hidden = true
+			case "NUM":
+				fld.NumericOnly = true
+			}
+		}
+		if fld.Write {
+			fld.Autoskip = false
+		}
+	}
+
+	if color, ok := stmt.operands["COLOR"]; ok {
+		colors := map[string]Color{"DEFAULT": DefaultColor, "BLUE": Blue,
+			"RED": Red, "PINK": Pink, "GREEN": Green,
+			"TURQUOISE": Turquoise, "YELLOW": Yellow, "NEUTRAL": White}
+		if fld.Color, ok = colors[color]; !ok {
+			return fld, fmt.Errorf("unknown COLOR %q", color)
+		}
+	}
+
+	if hilight, ok := stmt.operands["HILIGHT"]; ok {
+		highlights := map[string]Highlight{"OFF": DefaultHighlight,
+			"BLINK": Blink, "REVERSE": ReverseVideo,
+			"UNDERLINE": Underscore}
+		if fld.Highlighting, ok = highlights[hilight]; !ok {
+			return fld, fmt.Errorf("unknown HILIGHT %q", hilight)
+		}
+	}
+
+	if initial, ok := stmt.operands["INITIAL"]; ok {
+		if len(initial) < 2 || !strings.HasPrefix(initial, "'") ||
+			!strings.HasSuffix(initial, "'") {
+			return fld, fmt.Errorf("bad INITIAL %s", initial)
+		}
+		initial = initial[1 : len(initial)-1]
+		fld.Content = strings.Replace(initial, "''", "'", -1)
+	}
+
+	return fld, nil
+}
@@ -0,0 +1,64 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBMS(t *testing.T) {
+	lines := []string{
+		"* Customer inquiry map",
+		"CUSTMAP  DFHMSD TYPE=MAP,LANG=COBOL,MODE=INOUT",
+		"CUSTM    DFHMDI SIZE=(24,80)",
+		"         DFHMDF POS=(1,1),LENGTH=8,ATTRB=(PROT,BRT),               X",
+		"               INITIAL='CUSTOMER'S INQUIRY',COLOR=BLUE",
+		"CUSTNO   DFHMDF POS=(3,10),LENGTH=6,ATTRB=(UNPROT,NUM,IC),        X",
+		"               HILIGHT=UNDERLINE",
+		"         DFHMDF POS=(3,17),LENGTH=1    end of CUSTNO",
+		"         DFHMDF POS=160,LENGTH=4,INITIAL='NAME'",
+		"         DFHMSD TYPE=FINAL",
+		"         END",
+	}
+	// Fix the continuation characters in column 72
+	for i, line := range lines {
+		if strings.HasSuffix(line, "X") {
+			lines[i] = fmt.Sprintf("%-71sX", strings.TrimSuffix(line, "X"))
+		}
+	}
+	lines[4] = strings.Replace(lines[4], "CUSTOMER'S", "CUSTOMER''S", 1)
+
+	screen, err := ParseBMS(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Screen{
+		{Row: 0, Col: 0, Intense: true, Color: Blue,
+			Content: "CUSTOMER'S INQUIRY"},
+		{Row: 2, Col: 9, Name: "CUSTNO", Write: true, NumericOnly: true,
+			Highlighting: Underscore},
+		{Row: 2, Col: 16, Autoskip: true},
+		{Row: 2, Col: 0, Autoskip: true, Content: "NAME"},
+	}
+	if len(screen) != len(expected) {
+		t.Fatalf("expected %d fields, got %d: %+v", len(expected),
+			len(screen), screen)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(screen[i], expected[i]) {
+			t.Errorf("field %d: expected %+v, got %+v", i, expected[i],
+				screen[i])
+		}
+	}
+
+	if _, err := ParseBMS(strings.NewReader(
+		"         DFHMDF LENGTH=4")); err == nil {
+		t.Error("expected an error for a field without POS")
+	}
+}
@@ -9,12 +9,12 @@ import (
 )
 
 func TestEncode(t *testing.T) {
-	encoded := getpos(0, 0)
+	encoded := getpos(0, 0, defaultSize)
 	if encoded[0] != 0x40 || encoded[1] != 0x40 {
 		t.Error("Position (0, 0) not correctly encoded")
 	}
 
-	encoded = getpos(11, 39)
+	encoded = getpos(11, 39, defaultSize)
 	if encoded[0] != 0x4e || encoded[1] != 0xd7 {
 		t.Error("Position (11, 39) not correctly encoded")
 	}
@@ -31,3 +31,39 @@ func TestDecode(t *testing.T) {
 		t.Error("Buffer address incorrectly decoded")
 	}
 }
+
+func TestDecodeMixedAddressing(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      [2]byte
+		expected int
+	}{
+		// Last position of a 43x80 screen (row 42, col 79)
+		{"43x80 12-bit", [2]byte{0xf5, 0x6f}, 3439},
+		{"43x80 14-bit", [2]byte{0x0d, 0x6f}, 3439},
+		// Last position of a 27x132 screen (row 26, col 131)
+		{"27x132 12-bit", [2]byte{0xf7, 0x6b}, 3563},
+		{"27x132 14-bit", [2]byte{0x0d, 0xeb}, 3563},
+		// Row 1, col 0 of a 27x132 screen
+		{"27x132 12-bit row 1", [2]byte{0xc2, 0xc4}, 132},
+		{"27x132 14-bit row 1", [2]byte{0x00, 0x84}, 132},
+		// Beyond the 12-bit range, which requires 14-bit addressing
+		{"62x160 14-bit", [2]byte{0x26, 0xbf}, 9919},
+	}
+
+	for _, test := range tests {
+		if decoded := decodeBufAddr(test.raw); decoded != test.expected {
+			t.Errorf("%s: %02x %02x decoded to %d, expected %d", test.name,
+				test.raw[0], test.raw[1], decoded, test.expected)
+		}
+	}
+}
+
+func TestEncode14Bit(t *testing.T) {
+	// Last position of a 62x160 screen, beyond the 12-bit range
+	encoded := getpos(61, 159, screenSize{rows: 62, cols: 160})
+	if encoded[0] != 0x26 || encoded[1] != 0xbf {
+		t.Errorf("Position (61, 159) encoded as %02x %02x", encoded[0],
+			encoded[1])
+	}
+}
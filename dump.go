@@ -0,0 +1,209 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"fmt"
+	"strings"
+)
+
+// debugDatastream writes the annotated form of an outbound datastream,
+// including its telnet escaping and IAC EOR, to Debug when DebugDatastream
+// is set. Each line is preceded by prefix, if it isn't empty. Buffer
+// addresses are shown as positions on a screen of the given size.
+func debugDatastream(prefix string, size screenSize, datastream []byte) {
+	if Debug == nil || !DebugDatastream {
+		return
+	}
+	annotated := annotateDatastream(telnetUnescape(datastream), size)
+	if prefix != "" {
+		annotated = prefix + " " + strings.Replace(
+			strings.TrimSuffix(annotated, "\n"), "\n", "\n"+prefix+" ",
+			-1) + "\n"
+	}
+	fmt.Fprint(Debug, annotated)
+}
+
+// annotateDatastream returns a human-readable description of an outbound
+// 3270 datastream (without telnet escaping), with one command or order per
+// line. Buffer addresses are shown as positions on a screen of the given
+// size.
+func annotateDatastream(b []byte, size screenSize) string {
+	var sb strings.Builder
+	if len(b) == 0 {
+		return ""
+	}
+
+	switch b[0] {
+	case 0xf5:
+		sb.WriteString("Erase/Write")
+	case 0x7e:
+		sb.WriteString("Erase/Write Alternate")
+	case 0xf1:
+		sb.WriteString("Write")
+	case 0xf3:
+		fmt.Fprintf(&sb, "Write Structured Field: %x\n", b[1:])
+		return sb.String()
+	default:
+		fmt.Fprintf(&sb, "unknown command %02x: %x\n", b[0], b[1:])
+		return sb.String()
+	}
+	if len(b) < 2 {
+		sb.WriteString("\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, " WCC=%02x\n", b[1])
+
+	for i := 2; i < len(b); {
+		// need returns true if there are n bytes following the order at i
+		need := func(n int) bool {
+			if i+n >= len(b) {
+				fmt.Fprintf(&sb, "  truncated order: %x\n", b[i:])
+				return false
+			}
+			return true
+		}
+
+		switch b[i] {
+		case 0x11: // SBA
+			if !need(2) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  SBA at %s\n", annotateAddress(b[i+1], b[i+2],
+				size))
+			i += 3
+		case 0x1d: // SF
+			if !need(1) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  SF attr=%s\n", annotateAttribute(b[i+1]))
+			i += 2
+		case 0x29: // SFE
+			if !need(1) {
+				return sb.String()
+			}
+			count := int(b[i+1])
+			if !need(1 + count*2) {
+				return sb.String()
+			}
+			sb.WriteString("  SFE")
+			for p := 0; p < count; p++ {
+				sb.WriteString(" ")
+				sb.WriteString(annotatePair(b[i+2+p*2], b[i+3+p*2]))
+			}
+			sb.WriteString("\n")
+			i += 2 + count*2
+		case 0x28: // SA
+			if !need(2) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  SA %s\n", annotatePair(b[i+1], b[i+2]))
+			i += 3
+		case 0x13: // IC
+			sb.WriteString("  IC\n")
+			i++
+		case 0x05: // PT
+			sb.WriteString("  PT\n")
+			i++
+		case 0x08: // GE
+			if !need(1) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  GE char=%02x\n", b[i+1])
+			i += 2
+		case 0x12: // EUA
+			if !need(2) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  EUA to %s\n", annotateAddress(b[i+1], b[i+2],
+				size))
+			i += 3
+		case 0x3c: // RA
+			if !need(3) {
+				return sb.String()
+			}
+			fmt.Fprintf(&sb, "  RA to %s char=%02x\n",
+				annotateAddress(b[i+1], b[i+2], size), b[i+3])
+			i += 4
+		default:
+			// Character data continues until the next order.
+			start := i
+			for i < len(b) && !isOrder(b[i]) {
+				i++
+			}
+			fmt.Fprintf(&sb, "  content %q\n", e2a(b[start:i]))
+		}
+	}
+
+	return sb.String()
+}
+
+// isOrder returns true if b is one of the 3270 order codes recognized by
+// annotateDatastream.
+func isOrder(b byte) bool {
+	switch b {
+	case 0x05, 0x08, 0x11, 0x12, 0x13, 0x1d, 0x28, 0x29, 0x3c:
+		return true
+	}
+	return false
+}
+
+// annotateAddress describes an encoded buffer address as a row and column
+// on a screen of the given size.
+func annotateAddress(hi, lo byte, size screenSize) string {
+	addr := decodeBufAddr([2]byte{hi, lo})
+	return fmt.Sprintf("(%d,%d)", addr/size.cols, addr%size.cols)
+}
+
+// annotateAttribute describes a basic field attribute byte.
+func annotateAttribute(attr byte) string {
+	bits := decodes[attr]
+	if bits < 0 {
+		return fmt.Sprintf("%02x (invalid)", attr)
+	}
+
+	var flags []string
+	if bits&0x20 != 0 {
+		flags = append(flags, "protected")
+		if bits&0x10 != 0 {
+			flags = append(flags, "autoskip")
+		}
+	} else {
+		flags = append(flags, "unprotected")
+		if bits&0x10 != 0 {
+			flags = append(flags, "numeric")
+		}
+	}
+	switch bits & 0x0c {
+	case 0x08:
+		flags = append(flags, "intense")
+	case 0x0c:
+		flags = append(flags, "hidden")
+	}
+	if bits&0x01 != 0 {
+		flags = append(flags, "mdt")
+	}
+	return fmt.Sprintf("%02x (%s)", attr, strings.Join(flags, ","))
+}
+
+// annotatePair describes an extended attribute type/value pair from an SFE
+// or SA order.
+func annotatePair(typ, value byte) string {
+	switch typ {
+	case 0xc0:
+		return "attr=" + annotateAttribute(value)
+	case 0x41:
+		return fmt.Sprintf("highlight=%02x", value)
+	case 0x42:
+		return fmt.Sprintf("color=%02x", value)
+	case 0xc1:
+		return fmt.Sprintf("validation=%02x", value)
+	case 0xc2:
+		return fmt.Sprintf("outlining=%02x", value)
+	case 0x00:
+		return "reset"
+	}
+	return fmt.Sprintf("%02x=%02x", typ, value)
+}
@@ -0,0 +1,70 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnnotateDatastream(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Content: "Name"},
+		{Row: 0, Col: 10, Name: "name", Write: true, Color: Red},
+	}
+	datastream, _ := buildDatastream(screen, nil,
+		ScreenOpts{CursorRow: 0, CursorCol: 11})
+
+	expected := "Erase/Write WCC=c3\n" +
+		"  SBA at (0,0)\n" +
+		"  SF attr=60 (protected)\n" +
+		"  content \"Name\"\n" +
+		"  SBA at (0,10)\n" +
+		"  SFE attr=c1 (unprotected,mdt) color=f2\n" +
+		"  SBA at (0,11)\n" +
+		"  IC\n"
+	if got := annotateDatastream(telnetUnescape(datastream), defaultSize); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestAnnotateAlternateSize(t *testing.T) {
+	opts := ScreenOpts{Rows: 27, Cols: 132, NoCursorMove: true}
+	datastream, _ := buildDatastream(Screen{{Row: 26, Col: 100}}, nil, opts)
+
+	expected := "Erase/Write Alternate WCC=c3\n" +
+		"  SBA at (26,100)\n" +
+		"  SF attr=60 (protected)\n"
+	got := annotateDatastream(telnetUnescape(datastream), opts.size())
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestLogPrefix(t *testing.T) {
+	var out bytes.Buffer
+	Debug, DebugDatastream = &out, true
+	defer func() { Debug, DebugDatastream = nil, false }()
+
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0x40, 0xff, 0xef})}
+	_, err := ShowScreenOpts(Screen{{Row: 0, Col: 0, Content: "hi"}}, nil,
+		conn, ScreenOpts{LogPrefix: "conn-7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) < 4 {
+		t.Fatalf("expected debug output, got %q", out.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "dbg: conn-7 ") &&
+			!strings.HasPrefix(line, "conn-7 ") {
+			t.Errorf("line without prefix: %q", line)
+		}
+	}
+}
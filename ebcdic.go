@@ -4,6 +4,12 @@
 
 package go3270
 
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
 // Each index in this array is the ASCII value, and the value at the index is
 // the corresponding EBCDIC (codepage 37) value.
 var ebcdic = []byte{
@@ -62,3 +68,49 @@ func e2a(e []byte) []byte {
 	}
 	return result
 }
+
+// CompareEBCDIC compares a and b in EBCDIC collating sequence, the order
+// mainframe users expect lists to be sorted in: lowercase letters sort
+// before uppercase letters, and both sort before digits. The result is 0 if
+// a == b, -1 if a < b, and +1 if a > b. For example, to sort names:
+//
+//	sort.Slice(names, func(i, j int) bool {
+//		return go3270.CompareEBCDIC(names[i], names[j]) < 0
+//	})
+func CompareEBCDIC(a, b string) int {
+	return bytes.Compare(a2e([]byte(a)), a2e([]byte(b)))
+}
+
+// HexDumpEBCDIC returns a hex dump of the EBCDIC bytes in b, for debugging
+// field data. Each line shows the offset of its first byte, up to 16 bytes
+// in hex, and the same bytes decoded with the EBCDIC translation used for
+// field values. Bytes that decode to control characters are shown as ".".
+func HexDumpEBCDIC(b []byte) string {
+	var sb strings.Builder
+	for offset := 0; offset < len(b); offset += 16 {
+		line := b[offset:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+
+		fmt.Fprintf(&sb, "%04x ", offset)
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				fmt.Fprintf(&sb, " %02x", line[i])
+			} else {
+				sb.WriteString("   ")
+			}
+		}
+
+		sb.WriteString("  ")
+		for _, c := range e2a(line) {
+			if (c >= 0x20 && c < 0x7f) || c >= 0xa0 {
+				sb.WriteRune(rune(c))
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
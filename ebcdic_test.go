@@ -0,0 +1,35 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHexDumpEBCDIC(t *testing.T) {
+	b := append(a2e([]byte("HELLO, WORLD 123")), 0x00, 0xc1)
+	expected := "0000  c8 c5 d3 d3 d6 6b 40 e6 d6 d9 d3 c4 40 f1 f2 f3" +
+		"  HELLO, WORLD 123\n" +
+		"0010  00 c1" + strings.Repeat("   ", 14) + "  .A\n"
+	if got := HexDumpEBCDIC(b); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestCompareEBCDIC(t *testing.T) {
+	ordered := []string{"", "abc", "abd", "Abc", "ABC", "ABC1", "123"}
+	for i := 0; i < len(ordered)-1; i++ {
+		if CompareEBCDIC(ordered[i], ordered[i+1]) != -1 {
+			t.Errorf("expected %q < %q", ordered[i], ordered[i+1])
+		}
+		if CompareEBCDIC(ordered[i+1], ordered[i]) != 1 {
+			t.Errorf("expected %q > %q", ordered[i+1], ordered[i])
+		}
+	}
+	if CompareEBCDIC("same", "same") != 0 {
+		t.Error("expected equal strings to compare equal")
+	}
+}
@@ -0,0 +1,74 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+)
+
+// CommandHistory remembers the commands a user has entered in a command
+// field, to support the familiar mainframe RETRIEVE function: each press of
+// the retrieve key fills the command field with the next older command.
+// The zero value is an empty history ready to use. A CommandHistory belongs
+// to one user's connection and is not safe for concurrent use.
+type CommandHistory struct {
+	// Max is the number of commands remembered. If Max is 0, 20 commands
+	// are remembered.
+	Max int
+
+	entries []string // oldest first
+	pos     int      // number of entries retrieved since the last Add
+}
+
+// Add records cmd as the most recent command and resets retrieval to start
+// again from the most recent command. Blank commands are not recorded.
+func (h *CommandHistory) Add(cmd string) {
+	h.pos = 0
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+
+	max := h.Max
+	if max <= 0 {
+		max = 20
+	}
+	h.entries = append(h.entries, cmd)
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+	}
+}
+
+// Retrieve returns the most recent command the first time it is called
+// after Add(), and each older command in turn on later calls, starting again
+// from the most recent command after the oldest. It returns "" if the
+// history is empty.
+func (h *CommandHistory) Retrieve() string {
+	if len(h.entries) == 0 {
+		return ""
+	}
+	if h.pos >= len(h.entries) {
+		h.pos = 0
+	}
+	h.pos++
+	return h.entries[len(h.entries)-h.pos]
+}
+
+// Handle updates the history from resp, the response to a screen with a
+// command field named field. If the user pressed retrieveKey, the next
+// older command is placed in values[field], so that it is pre-filled when
+// values are used to display the screen again, and Handle returns true.
+// Otherwise the command the user entered, if any, is added to the history
+// and Handle returns false.
+func (h *CommandHistory) Handle(resp Response, field string,
+	retrieveKey AID, values map[string]string) bool {
+
+	if resp.AID == retrieveKey {
+		values[field] = h.Retrieve()
+		return true
+	}
+	h.Add(resp.Values[field])
+	return false
+}
@@ -0,0 +1,31 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"testing"
+)
+
+func TestCommandHistory(t *testing.T) {
+	h := CommandHistory{Max: 2}
+	if cmd := h.Retrieve(); cmd != "" {
+		t.Errorf("expected empty history, got %q", cmd)
+	}
+
+	for _, cmd := range []string{"ONE", " ", "TWO", "THREE"} {
+		h.Handle(Response{AID: AIDEnter,
+			Values: map[string]string{"cmd": cmd}}, "cmd", AIDPF12, nil)
+	}
+
+	values := make(map[string]string)
+	for _, expected := range []string{"THREE", "TWO", "THREE"} {
+		if !h.Handle(Response{AID: AIDPF12}, "cmd", AIDPF12, values) {
+			t.Fatal("expected the retrieve key to be handled")
+		}
+		if values["cmd"] != expected {
+			t.Errorf("expected %q, got %q", expected, values["cmd"])
+		}
+	}
+}
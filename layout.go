@@ -0,0 +1,228 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// StopField returns a protected field at row, col to end the writable field
+// before it. Without a following field, a writable field's attributes and
+// input area extend until the next field on the screen. When the user types
+// to the end of the writable field, further typing is rejected by the
+// client until the user moves the cursor.
+func StopField(row, col int) Field {
+	return Field{Row: row, Col: col}
+}
+
+// AutoskipStop returns an autoskip field at row, col to end the writable
+// field before it. When the user types to the end of the writable field,
+// the cursor skips ahead to the next writable field, as if the user pressed
+// tab.
+func AutoskipStop(row, col int) Field {
+	return Field{Row: row, Col: col, Autoskip: true}
+}
+
+// FieldOneBased returns f positioned at the 1-based row and col used by ISPF
+// panels, BMS maps, and most mainframe documentation, where the top left
+// corner of the screen is row 1, column 1. go3270 positions are 0-based,
+// so the field's Row and Col are set to row-1 and col-1. Any Row and Col
+// already in f are ignored. For example, a BMS map field at POS=(5,20)
+// becomes:
+//
+//	FieldOneBased(5, 20, go3270.Field{Name: "custno", Write: true})
+func FieldOneBased(row, col int, f Field) Field {
+	f.Row, f.Col = row-1, col-1
+	return f
+}
+
+// Slot is a fixed area of the screen for displaying text: the field
+// attribute is at Row, Col, and Width characters of text follow it.
+type Slot struct {
+	Row   int
+	Col   int
+	Width int
+}
+
+// ContinuationFields splits value across the slots, in order, and returns a
+// display-only field for each slot. This is useful for showing a long value
+// in a set of pre-placed continuation lines, such as the lines of an address
+// or comment area. The fields are named name_1, name_2, etc., so their
+// content may later be replaced with the values map. Any part of value that
+// doesn't fit in the slots is not displayed. Value is split by character, not
+// by word. As with MaxWidth, each byte of value takes one position, and a
+// multi-byte UTF-8 character that doesn't fit at the end of a slot starts
+// the next one.
+func ContinuationFields(name, value string, slots []Slot) Screen {
+	remaining := value
+	screen := make(Screen, 0, len(slots))
+	for i, slot := range slots {
+		width := slot.Width
+		if width < 0 {
+			width = 0
+		}
+		content := truncateContent(remaining, width)
+		screen = append(screen, Field{
+			Row:     slot.Row,
+			Col:     slot.Col,
+			Name:    fmt.Sprintf("%s_%d", name, i+1),
+			Content: content,
+		})
+		remaining = remaining[len(content):]
+	}
+	return screen
+}
+
+// JoinFields returns the values of the named fields in resp joined into one
+// logical value, for a multi-line text area built from stacked single-row
+// writable fields. The lines are joined with a single space, as a paragraph
+// that SplitFields() wrapped across the fields; blank lines are skipped.
+func JoinFields(resp Response, names []string) string {
+	var parts []string
+	for _, name := range names {
+		if line := strings.TrimSpace(resp.Values[name]); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// SplitFields word-wraps value into lines of at most width characters and
+// returns a values map assigning one line to each of the named fields, in
+// order, to populate a text area built from stacked fields. Words longer
+// than width are broken. Fields left over after the value runs out are set
+// to "" so that any previous content is cleared, and any part of value that
+// doesn't fit in the fields is not included.
+func SplitFields(value string, names []string, width int) map[string]string {
+	result := make(map[string]string)
+	words := strings.Fields(value)
+	for _, name := range names {
+		var line string
+		for len(words) > 0 && width > 0 {
+			word := words[0]
+			if line == "" && len(word) > width {
+				// Break a word that can't fit on any line
+				line, words[0] = word[:width], word[width:]
+				break
+			}
+			if line != "" && len(line)+1+len(word) > width {
+				break
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+			words = words[1:]
+		}
+		result[name] = line
+	}
+	return result
+}
+
+// TextArea lays out text as a block of display-only fields, one per line,
+// for showing a file or log. The first field's attribute is at row, col,
+// and each line of text is displayed after it in up to width screen
+// positions, on up to height rows. Lines of text (separated by "\n") longer
+// than width are wrapped onto the next row; width is measured as the
+// content will be sent, so a character is never split. The text that
+// didn't fit is returned as remaining, to be displayed on the next page,
+// e.g. when the user presses PF8.
+func TextArea(row, col, width, height int, text string) (screen Screen,
+	remaining string) {
+
+	if width < 1 {
+		return nil, text
+	}
+	for i := 0; i < height && text != ""; i++ {
+		line := text
+		rest := ""
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			line, rest = text[:nl], text[nl+1:]
+		}
+		chunk := truncateContent(line, width)
+		if chunk == "" && line != "" {
+			// Always make progress, even if the first character is
+			// wider than the area.
+			_, size := utf8.DecodeRuneInString(line)
+			chunk = line[:size]
+		}
+		if len(chunk) < len(line) {
+			// Wrap the rest of the line onto the next row
+			rest = line[len(chunk):] + text[len(line):]
+		}
+		screen = append(screen, Field{Row: row + i, Col: col,
+			Content: chunk})
+		text = rest
+	}
+	return screen, text
+}
+
+// Code page 310 box drawing characters, sent with the Graphic Escape order.
+const (
+	geOrder      = 0x08
+	geUpperLeft  = 0xc5
+	geUpperRight = 0xd5
+	geLowerLeft  = 0xc4
+	geLowerRight = 0xd4
+	geHorizontal = 0xa2
+	geVertical   = 0x85
+)
+
+// graphicRunes are the Unicode equivalents of the code page 310 characters
+// used by Box().
+var graphicRunes = map[byte]rune{
+	geUpperLeft:  '┌',
+	geUpperRight: '┐',
+	geLowerLeft:  '└',
+	geLowerRight: '┘',
+	geHorizontal: '─',
+	geVertical:   '│',
+}
+
+// Box returns display-only fields drawing a box with its corners at row top,
+// column left and row top+height-1, column left+width-1, using the line
+// drawing characters of the 3270 graphic character set (code page 310). The
+// minimum height and width are 2.
+//
+// Each side of the box is a field, so the position before it holds the
+// field attribute: left must be at least 1. Column left holds the left
+// side, so fields inside the box may start at column left+1 (with content
+// from left+2) and must end before column left+width-2, which holds the
+// attribute of the right side.
+//
+// Not all clients support the graphic escape order used for the line
+// characters. Each field has a Fallback using +, -, and | instead, which is
+// used when the screen is displayed with ScreenOpts.NoExtended.
+func Box(top, left, height, width int) Screen {
+	if height < 2 || width < 2 {
+		return nil
+	}
+
+	line := func(row int, l, m, r byte, al, am, ar string) Field {
+		raw := []byte{geOrder, l}
+		for i := 0; i < width-2; i++ {
+			raw = append(raw, geOrder, m)
+		}
+		raw = append(raw, geOrder, r)
+		return Field{Row: row, Col: left - 1, RawContent: raw,
+			Fallback: &Field{Row: row, Col: left - 1,
+				Content: al + strings.Repeat(am, width-2) + ar}}
+	}
+
+	screen := Screen{line(top, geUpperLeft, geHorizontal, geUpperRight,
+		"+", "-", "+")}
+	for row := top + 1; row < top+height-1; row++ {
+		for _, col := range []int{left - 1, left + width - 2} {
+			screen = append(screen, Field{Row: row, Col: col,
+				RawContent: []byte{geOrder, geVertical},
+				Fallback:   &Field{Row: row, Col: col, Content: "|"}})
+		}
+	}
+	screen = append(screen, line(top+height-1, geLowerLeft, geHorizontal,
+		geLowerRight, "+", "-", "+"))
+	return screen
+}
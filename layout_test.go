@@ -0,0 +1,152 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitJoinFields(t *testing.T) {
+	names := []string{"l1", "l2", "l3", "l4"}
+	values := SplitFields("the quick brown fox jumps abcdefghijkl", names, 10)
+
+	expected := map[string]string{"l1": "the quick", "l2": "brown fox",
+		"l3": "jumps", "l4": "abcdefghij"}
+	for name, line := range expected {
+		if values[name] != line {
+			t.Errorf("%s: expected %q, got %q", name, line, values[name])
+		}
+	}
+
+	joined := JoinFields(Response{Values: values}, names)
+	if joined != "the quick brown fox jumps abcdefghij" {
+		t.Errorf("unexpected joined value %q", joined)
+	}
+}
+
+func TestTextArea(t *testing.T) {
+	screen, remaining := TextArea(2, 0, 4, 3, "abcdefg\n\nhi\nlast")
+
+	expected := []string{"abcd", "efg", ""}
+	if len(screen) != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), len(screen))
+	}
+	for i, content := range expected {
+		if screen[i].Row != 2+i || screen[i].Col != 0 ||
+			screen[i].Content != content {
+			t.Errorf("field %d: expected %q at (%d, 0), got %+v", i,
+				content, 2+i, screen[i])
+		}
+	}
+	if remaining != "hi\nlast" {
+		t.Errorf("unexpected remaining text %q", remaining)
+	}
+}
+
+func TestBox(t *testing.T) {
+	box := Box(5, 10, 3, 5)
+	if len(box) != 4 {
+		t.Fatalf("expected 4 fields, got %d", len(box))
+	}
+
+	top := box[0]
+	if top.Row != 5 || top.Col != 9 {
+		t.Errorf("top line at %d,%d, expected 5,9", top.Row, top.Col)
+	}
+	expected := []byte{0x08, 0xc5, 0x08, 0xa2, 0x08, 0xa2, 0x08, 0xa2,
+		0x08, 0xd5}
+	if !bytes.Equal(top.RawContent, expected) {
+		t.Errorf("top line %x, expected %x", top.RawContent, expected)
+	}
+	if top.Fallback == nil || top.Fallback.Content != "+---+" {
+		t.Errorf("top line fallback %+v, expected +---+", top.Fallback)
+	}
+
+	right := box[2]
+	if right.Row != 6 || right.Col != 13 {
+		t.Errorf("right side at %d,%d, expected 6,13", right.Row, right.Col)
+	}
+
+	if errs := box.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+	if Box(0, 0, 1, 5) != nil {
+		t.Error("expected nil for a box with fewer than 2 rows")
+	}
+}
+
+func TestFieldOneBased(t *testing.T) {
+	f := FieldOneBased(1, 1, Field{Name: "a", Write: true})
+	if f.Row != 0 || f.Col != 0 || f.Name != "a" || !f.Write {
+		t.Errorf("unexpected field %+v", f)
+	}
+	if f := FieldOneBased(24, 80, Field{}); f.Row != 23 || f.Col != 79 {
+		t.Errorf("expected (23, 79), got (%d, %d)", f.Row, f.Col)
+	}
+}
+
+func TestContinuationFields(t *testing.T) {
+	slots := []Slot{{Row: 3, Col: 0, Width: 4}, {Row: 4, Col: 0, Width: 4},
+		{Row: 5, Col: 0, Width: 4}}
+
+	// "é" is two bytes, so it doesn't fit after "abc" and starts the
+	// second slot, as MaxWidth would truncate it.
+	screen := ContinuationFields("addr", "abcédefghijk", slots)
+	expected := []string{"abc", "éde", "fghi"}
+	if len(screen) != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), len(screen))
+	}
+	for i, content := range expected {
+		fld := screen[i]
+		if fld.Row != 3+i || fld.Content != content ||
+			fld.Name != "addr_"+string(rune('1'+i)) {
+			t.Errorf("field %d: expected %q, got %+v", i, content, fld)
+		}
+		if len(fld.Content) > slots[i].Width {
+			t.Errorf("field %d: %q is wider than the slot", i, fld.Content)
+		}
+	}
+
+	// Nothing is lost or reordered between the slots
+	joined := screen[0].Content + screen[1].Content + screen[2].Content
+	if joined != "abcédefghi" {
+		t.Errorf("unexpected joined value %q", joined)
+	}
+
+	screen = ContinuationFields("c", "hi", slots)
+	if screen[0].Content != "hi" || screen[1].Content != "" ||
+		screen[2].Content != "" {
+		t.Errorf("expected only the first slot filled, got %+v", screen)
+	}
+}
+
+func TestStopFields(t *testing.T) {
+	screen := Screen{
+		{Row: 2, Col: 0, Name: "a", Write: true},
+		StopField(2, 10),
+		{Row: 3, Col: 0, Name: "b", Write: true},
+		AutoskipStop(3, 10),
+	}
+	datastream, fm := buildDatastream(screen, nil, ScreenOpts{})
+
+	// A protected field, and a protected numeric (autoskip) field
+	stop := append(sba(2, 10, defaultSize), 0x1d, 0x60)
+	if !bytes.Contains(datastream, stop) {
+		t.Errorf("expected %x in %x", stop, datastream)
+	}
+	skip := append(sba(3, 10, defaultSize), 0x1d, 0xf0)
+	if !bytes.Contains(datastream, skip) {
+		t.Errorf("expected %x in %x", skip, datastream)
+	}
+
+	// Neither is an input field, and both end the line's input field
+	if len(fm) != 2 {
+		t.Errorf("expected 2 input fields, got %v", fm)
+	}
+	if errs := screen.Validate(); errs != nil {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+}
@@ -6,9 +6,10 @@ package go3270
 
 import (
 	"fmt"
-	"net"
 	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Rules is a map of field names (strings) to FieldRules structs. Each field
@@ -37,6 +38,14 @@ var IsInteger Validator = func(input string) bool {
 	return isIntegerRegexp.MatchString(input)
 }
 
+// MaxLen returns a Validator that returns true if the value is no more than
+// n characters long.
+func MaxLen(n int) Validator {
+	return func(input string) bool {
+		return utf8.RuneCountInString(input) <= n
+	}
+}
+
 // FieldRules provides the validation rules for a particular field.
 type FieldRules struct {
 	// MustChange, when true, indicates that the value of the field MUST be
@@ -86,20 +95,92 @@ type FieldRules struct {
 // fields pass validation, OR 2) the user presses a key in exitkeys. In all
 // other cases, HandleScreen will re-present the screen to the user again,
 // possibly with an error message set in the errorField field.
+//
+// The Response for an exit key contains the values of all of the screen's
+// writable fields, as for any other key, without validation, so they may be
+// kept (as a draft to resume later, for example). The exception is the
+// Clear and PA keys: by the 3270 protocol these send no field data, so
+// their Response has no Values.
 func HandleScreen(screen Screen, rules Rules, values map[string]string,
 	pfkeys, exitkeys []AID, errorField string, crow, ccol int,
-	conn net.Conn) (Response, error) {
-
-	// Save the original field values for any named fields to support
-	// the MustChange rule. Also build a map of named fields.
-	origValues := make(map[string]string)
-	fields := make(map[string]*Field)
-	for i := range screen {
-		if screen[i].Name != "" {
-			origValues[screen[i].Name] = screen[i].Content
-			fields[screen[i].Name] = &screen[i]
-		}
-	}
+	conn Transport) (Response, error) {
+
+	return HandleScreenOpts(screen, rules, values, pfkeys, exitkeys,
+		errorField, conn, HandleOpts{
+			ScreenOpts: ScreenOpts{CursorRow: crow, CursorCol: ccol},
+		})
+}
+
+// HandleOpts are the options for HandleScreenOpts().
+type HandleOpts struct {
+	// ScreenOpts are the options used each time the screen is displayed,
+	// including the initial cursor position.
+	ScreenOpts
+
+	// UnknownKeyHandler, if not nil, is called when the user presses a key
+	// that is in neither pfkeys nor exitkeys. If it returns handled=true,
+	// errMsg (which may be empty) is displayed in the error field instead
+	// of the default "<key>: unknown key" message. Either way, the screen is
+	// displayed again; the handler may perform other work before returning,
+	// such as displaying a help screen.
+	UnknownKeyHandler func(aid AID, resp Response) (handled bool,
+		errMsg string)
+
+	// TransformValues, if not nil, is called once with the response's field
+	// values after all fields pass validation, and the map it returns
+	// becomes the Values of the Response returned by HandleScreenOpts().
+	// It may modify and return the map it is given. This is useful for
+	// normalizing values or deriving computed values, such as combining
+	// the parts of a date into one value. It is not called for exit keys.
+	TransformValues func(values map[string]string) map[string]string
+
+	// FieldHandlers, if not nil, maps writable field names to handlers for
+	// keys pressed with the cursor in that field (see
+	// Response.CursorField). When the user presses a key in pfkeys and all
+	// fields pass validation, the handler for the field the cursor is in,
+	// if any, is called. If it returns done=true, HandleScreenOpts()
+	// returns the response as usual. Otherwise the screen is displayed
+	// again with errMsg (which may be empty) in the error field. For
+	// example, a handler for a command field can execute the command and
+	// re-display the screen, while Enter pressed anywhere else returns.
+	FieldHandlers map[string]func(resp Response) (done bool, errMsg string)
+
+	// FailureDelay is how long to wait before displaying the screen again
+	// after the user's input fails validation (MaxLength, MustChange, or a
+	// Validator). It does not apply when a field handler returns
+	// done=false. The client's keyboard remains locked until the screen is
+	// displayed, so this slows down repeated attempts, such as guessing
+	// passwords on a login screen. Combine it with FieldRules.Reset to clear
+	// the password field after a failure.
+	FailureDelay time.Duration
+
+	// FailureCursorField, if not empty, is the name of the field to place
+	// the cursor in when the screen is displayed again after a failure, as
+	// for FailureDelay, instead of the position from ScreenOpts. For
+	// example, a login screen can return the user to the username field.
+	FailureCursorField string
+}
+
+// HandleScreenOpts is HandleScreen() with additional options. The initial
+// cursor position is taken from opts.ScreenOpts.
+func HandleScreenOpts(screen Screen, rules Rules, values map[string]string,
+	pfkeys, exitkeys []AID, errorField string, conn Transport,
+	opts HandleOpts) (Response, error) {
+
+	return HandleScreenFunc(func() (Screen, Rules) { return screen, rules },
+		values, pfkeys, exitkeys, errorField, conn, opts)
+}
+
+// HandleScreenFunc is HandleScreenOpts() for a screen that is generated
+// fresh each time it is displayed. build is called to produce the screen and
+// its rules before the screen is first displayed and again each time
+// HandleScreenFunc re-presents it, so the screen can reflect current data.
+// The values the user entered are carried over between iterations as with
+// HandleScreenOpts(), and MustChange rules compare against the Content of
+// the fields in the most recently built screen.
+func HandleScreenFunc(build func() (Screen, Rules),
+	values map[string]string, pfkeys, exitkeys []AID, errorField string,
+	conn Transport, opts HandleOpts) (Response, error) {
 
 	// Make our own field values map so we don't alter the caller's values
 	myValues := make(map[string]string)
@@ -108,8 +189,32 @@ func HandleScreen(screen Screen, rules Rules, values map[string]string,
 	}
 
 	// Now we loop...
+	failed := false
 mainloop:
 	for {
+		screen, rules := build()
+
+		screenOpts := opts.ScreenOpts
+		if failed {
+			time.Sleep(opts.FailureDelay)
+			if opts.FailureCursorField != "" {
+				screenOpts.CursorField = opts.FailureCursorField
+				screenOpts.CursorFieldOffset = 0
+			}
+			failed = false
+		}
+
+		// Save the original field values for any named fields to support
+		// the MustChange rule. Also build a map of named fields.
+		origValues := make(map[string]string)
+		fields := make(map[string]*Field)
+		for i := range screen {
+			if screen[i].Name != "" {
+				origValues[screen[i].Name] = screen[i].Content
+				fields[screen[i].Name] = &screen[i]
+			}
+		}
+
 		// Reset fields with FieldRules.Reset set
 		for field := range rules {
 			if rules[field].Reset {
@@ -127,7 +232,7 @@ mainloop:
 			}
 		}
 
-		resp, err := ShowScreen(screen, myValues, crow, ccol, conn)
+		resp, err := ShowScreenOpts(screen, myValues, conn, screenOpts)
 		if err != nil {
 			return resp, err
 		}
@@ -143,8 +248,14 @@ mainloop:
 				resp.AID == AIDPA3) {
 				myValues = mergeFieldValues(myValues, resp.Values)
 			}
-			myValues[errorField] = fmt.Sprintf("%s: unknown key",
-				AIDtoString(resp.AID))
+			msg := fmt.Sprintf("%s: unknown key", AIDtoString(resp.AID))
+			if opts.UnknownKeyHandler != nil {
+				if handled, errMsg := opts.UnknownKeyHandler(resp.AID,
+					resp); handled {
+					msg = errMsg
+				}
+			}
+			myValues[errorField] = msg
 			continue
 		}
 
@@ -158,6 +269,17 @@ mainloop:
 		myValues = mergeFieldValues(myValues, resp.Values)
 		delete(myValues, errorField) // don't persist errors across refreshes
 
+		// Enforce the maximum length of writable fields
+		for _, fld := range screen {
+			if fld.Write && fld.MaxLength > 0 &&
+				!MaxLen(fld.MaxLength)(myValues[fld.Name]) {
+				myValues[errorField] = fmt.Sprintf(
+					"Value for %s is too long", fld.Name)
+				failed = true
+				continue mainloop
+			}
+		}
+
 		// Now we can validate each field
 		for field := range rules {
 			// skip rules for fields that don't exist
@@ -166,15 +288,27 @@ mainloop:
 			}
 			if rules[field].MustChange && myValues[field] == origValues[field] {
 				myValues[errorField] = rules[field].ErrorText
+				failed = true
 				continue mainloop
 			}
 			if rules[field].Validator != nil && !rules[field].Validator(myValues[field]) {
 				myValues[errorField] = fmt.Sprintf("Value for %s is not valid", field)
+				failed = true
 				continue mainloop
 			}
 		}
 
 		// Everything passed validation
+		if handler, ok := opts.FieldHandlers[resp.CursorField]; ok &&
+			resp.CursorField != "" {
+			if done, errMsg := handler(resp); !done {
+				myValues[errorField] = errMsg
+				continue
+			}
+		}
+		if opts.TransformValues != nil {
+			resp.Values = opts.TransformValues(resp.Values)
+		}
 		return resp, nil
 	}
 }
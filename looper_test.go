@@ -0,0 +1,279 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestHandleScreenTransformValues(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "month", Write: true},
+		{Row: 0, Col: 3, Name: "year", Write: true},
+		{Row: 0, Col: 8},
+	}
+	// Enter with "03" in month and "2020" in year
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1,
+		0x11, 0x40, 0xc1, 0xf0, 0xf3,
+		0x11, 0x40, 0xc4, 0xf2, 0xf0, 0xf2, 0xf0,
+		0xff, 0xef})}
+
+	resp, err := HandleScreenOpts(screen, nil, nil, []AID{AIDEnter}, nil,
+		"", conn, HandleOpts{
+			TransformValues: func(v map[string]string) map[string]string {
+				v["date"] = v["year"] + "-" + v["month"]
+				return v
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Values["date"] != "2020-03" {
+		t.Errorf("expected transformed date 2020-03, got %q",
+			resp.Values["date"])
+	}
+}
+
+func TestHandleScreenFieldHandlers(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "cmd", Write: true},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "data", Write: true},
+		{Row: 1, Col: 10},
+	}
+	// Enter with the cursor in cmd (address 1), then Enter with the cursor
+	// in data (address 81)
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1, 0xff, 0xef,
+		0x7d, 0xc1, 0xd1, 0xff, 0xef})}
+
+	var calls int
+	resp, err := HandleScreenOpts(screen, nil, nil, []AID{AIDEnter}, nil,
+		"", conn, HandleOpts{
+			FieldHandlers: map[string]func(Response) (bool, string){
+				"cmd": func(resp Response) (bool, string) {
+					calls++
+					return false, ""
+				},
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the cmd handler to be called once, got %d", calls)
+	}
+	if resp.CursorField != "data" {
+		t.Errorf("expected cursor in data, got %q", resp.CursorField)
+	}
+}
+
+func TestHandleScreenFieldHandlerNotFailure(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "cmd", Write: true},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "data", Write: true},
+		{Row: 1, Col: 10},
+	}
+	// Enter with the cursor in cmd (address 1), then Enter with the cursor
+	// in data (address 81)
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1, 0xff, 0xef,
+		0x7d, 0xc1, 0xd1, 0xff, 0xef})}
+
+	// A handler that runs its command and asks for the screen again hasn't
+	// failed, so neither FailureDelay nor FailureCursorField apply.
+	start := time.Now()
+	_, err := HandleScreenOpts(screen, nil, nil, []AID{AIDEnter}, nil,
+		"", conn, HandleOpts{
+			FieldHandlers: map[string]func(Response) (bool, string){
+				"cmd": func(resp Response) (bool, string) {
+					return false, ""
+				},
+			},
+			FailureDelay:       time.Second,
+			FailureCursorField: "data",
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected no failure delay, took %v", elapsed)
+	}
+	if bytes.Contains(conn.written.Bytes(), ic(1, 1, defaultSize)) {
+		t.Error("expected the cursor not to be moved to data")
+	}
+}
+
+func TestHandleScreenUnknownKeyHandler(t *testing.T) {
+	screen := Screen{{Row: 0, Col: 0, Name: "msg"}}
+	// PF5 and PF6 (not accepted), then Enter
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0xf5, 0x40, 0x40, 0xff, 0xef,
+		0xf6, 0x40, 0x40, 0xff, 0xef,
+		0x7d, 0x40, 0x40, 0xff, 0xef})}
+
+	var keys []AID
+	resp, err := HandleScreenOpts(screen, nil, nil, []AID{AIDEnter}, nil,
+		"msg", conn, HandleOpts{
+			UnknownKeyHandler: func(aid AID, resp Response) (bool, string) {
+				keys = append(keys, aid)
+				return aid == AIDPF5, "PF5 IS HELP"
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter {
+		t.Errorf("expected Enter, got %s", AIDtoString(resp.AID))
+	}
+	if len(keys) != 2 || keys[0] != AIDPF5 || keys[1] != AIDPF6 {
+		t.Errorf("expected the handler to be called for PF5 and PF6, got %v",
+			keys)
+	}
+
+	// The handled key shows the handler's message, and the other the
+	// default one
+	written := conn.written.Bytes()
+	if !bytes.Contains(written, a2e([]byte("PF5 IS HELP"))) {
+		t.Error("expected the handler's message for PF5")
+	}
+	if bytes.Contains(written, a2e([]byte("PF5: unknown key"))) {
+		t.Error("expected no default message for PF5")
+	}
+	if !bytes.Contains(written, a2e([]byte("PF6: unknown key"))) {
+		t.Error("expected the default message for PF6")
+	}
+}
+
+func TestHandleScreenFunc(t *testing.T) {
+	// PF5 (not accepted), then Enter
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0xf5, 0x40, 0xc1, 0xff, 0xef,
+		0x7d, 0x40, 0xc1, 0xff, 0xef})}
+
+	var builds int
+	build := func() (Screen, Rules) {
+		builds++
+		return Screen{{Row: 0, Col: 0, Name: "msg"}}, nil
+	}
+	resp, err := HandleScreenFunc(build, nil, []AID{AIDEnter}, nil, "msg",
+		conn, HandleOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter || builds != 2 {
+		t.Errorf("expected Enter after 2 builds, got %s after %d",
+			AIDtoString(resp.AID), builds)
+	}
+}
+
+func TestHandleScreenExitKeyValues(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "title", Write: true},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "body", Write: true},
+		{Row: 1, Col: 10},
+	}
+	rules := Rules{"title": {Validator: NonBlank}}
+	// PF3 with title left empty and "DRAFT" in body
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0xf3, 0xc1, 0xd1,
+		0x11, 0x40, 0xc1,
+		0x11, 0xc1, 0xd1, 0xc4, 0xd9, 0xc1, 0xc6, 0xe3,
+		0xff, 0xef})}
+
+	resp, err := HandleScreenOpts(screen, rules, nil, []AID{AIDEnter},
+		[]AID{AIDPF3}, "", conn, HandleOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDPF3 {
+		t.Fatalf("expected PF3, got %s", AIDtoString(resp.AID))
+	}
+	if title, ok := resp.Values["title"]; !ok || title != "" {
+		t.Errorf("expected empty title value, got %q %v", title, ok)
+	}
+	if resp.Values["body"] != "DRAFT" {
+		t.Errorf("expected body DRAFT, got %q", resp.Values["body"])
+	}
+}
+
+func TestHandleScreenFailureDelay(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "user", Write: true},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "password", Write: true, Hidden: true},
+		{Row: 1, Col: 10},
+	}
+	rules := Rules{"password": {Validator: NonBlank, Reset: true}}
+	// Enter with the cursor in password (address 81) and it left blank,
+	// then Enter with "A" in password
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0xc1, 0xd1, 0x11, 0xc1, 0xd1, 0xff, 0xef,
+		0x7d, 0xc1, 0xd2, 0x11, 0xc1, 0xd1, 0xc1, 0xff, 0xef})}
+
+	start := time.Now()
+	resp, err := HandleScreenOpts(screen, rules, nil, []AID{AIDEnter}, nil,
+		"", conn, HandleOpts{
+			ScreenOpts:         ScreenOpts{CursorField: "password"},
+			FailureDelay:       50 * time.Millisecond,
+			FailureCursorField: "user",
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected a delay of at least 50ms, took %v", elapsed)
+	}
+	if resp.Values["password"] != "A" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// The first display has the cursor in password, the second in user
+	written := conn.written.Bytes()
+	if first := ic(1, 1, defaultSize); !bytes.Contains(written, first) {
+		t.Errorf("expected %x in first datastream", first)
+	}
+	second := append(ic(0, 1, defaultSize), iac, eor)
+	if !bytes.HasSuffix(written, second) {
+		t.Errorf("expected second datastream to end with %x, got %x",
+			second, written)
+	}
+}
+
+func TestHandleScreenMaxLength(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "code", Write: true, MaxLength: 3},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "msg"},
+	}
+	// Enter with "ABCD" in code, then Enter with "ABC"
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xc2, 0xc3, 0xc4,
+		0xff, 0xef,
+		0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xc2, 0xc3,
+		0xff, 0xef})}
+
+	resp, err := HandleScreenOpts(screen, nil, nil, []AID{AIDEnter}, nil,
+		"msg", conn, HandleOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Values["code"] != "ABC" {
+		t.Errorf("expected ABC, got %q", resp.Values["code"])
+	}
+	if msg := a2e([]byte("Value for code is too long")); !bytes.Contains(
+		conn.written.Bytes(), msg) {
+		t.Error("expected the too long message to be displayed")
+	}
+
+	if !MaxLen(3)("abc") || MaxLen(3)("abcd") {
+		t.Error("unexpected MaxLen result")
+	}
+}
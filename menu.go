@@ -0,0 +1,79 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCommand is returned (wrapped with the value the user entered)
+// by CommandField.Command() when the command field contains a value that is
+// not one of the field's commands.
+var ErrUnknownCommand = errors.New("go3270: unknown command")
+
+// MenuChoice returns the numbered menu option the user selected in resp,
+// following the usual mainframe convention that an option may be chosen
+// either by typing its number in the option field and pressing Enter, or by
+// pressing the PF key with the same number. optionField is the name of the
+// option input field. ok is false if the user pressed Enter without a valid
+// positive number in the option field, or pressed a key other than Enter or
+// a PF key.
+func MenuChoice(resp Response, optionField string) (choice int, ok bool) {
+	if n := resp.AID.PFNumber(); n > 0 {
+		return n, true
+	}
+
+	if resp.AID != AIDEnter {
+		return 0, false
+	}
+
+	choice, err := strconv.Atoi(strings.TrimSpace(resp.Values[optionField]))
+	if err != nil || choice < 1 {
+		return 0, false
+	}
+	return choice, true
+}
+
+// CommandField describes an ISPF-style command or option input field
+// ("Option ===>"), so that blank and unrecognized entries are handled the
+// same way on every screen.
+type CommandField struct {
+	// Name is the name of the command input field.
+	Name string
+
+	// Default is the command to use when the user leaves the field blank,
+	// such as a "refresh" command. It need not appear in Commands.
+	Default string
+
+	// Commands are the valid commands. The user's entry is matched without
+	// regard to case or leading and trailing spaces.
+	Commands []string
+}
+
+// Command returns the command the user selected in resp. As with
+// MenuChoice(), pressing a PF key selects the command with that key's
+// number, so "3" is returned for PF3 if "3" is one of the commands. A blank
+// field returns the Default command, and the matching entry from Commands
+// is returned otherwise. An error wrapping ErrUnknownCommand is returned if
+// the entry doesn't match any of the commands.
+func (c CommandField) Command(resp Response) (string, error) {
+	value := strings.TrimSpace(resp.Values[c.Name])
+	if n := resp.AID.PFNumber(); n > 0 {
+		value = strconv.Itoa(n)
+	}
+
+	if value == "" {
+		return c.Default, nil
+	}
+	for _, cmd := range c.Commands {
+		if strings.EqualFold(value, cmd) {
+			return cmd, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownCommand, value)
+}
@@ -0,0 +1,81 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCommandField(t *testing.T) {
+	cf := CommandField{Name: "option", Default: "REFRESH",
+		Commands: []string{"1", "2", "EXIT"}}
+
+	tests := []struct {
+		aid      AID
+		value    string
+		expected string
+	}{
+		{AIDEnter, "", "REFRESH"},
+		{AIDEnter, "   ", "REFRESH"},
+		{AIDEnter, " exit ", "EXIT"},
+		{AIDEnter, "2", "2"},
+		{AIDPF1, "", "1"},
+	}
+	for _, test := range tests {
+		resp := Response{AID: test.aid,
+			Values: map[string]string{"option": test.value}}
+		cmd, err := cf.Command(resp)
+		if err != nil || cmd != test.expected {
+			t.Errorf("%q: expected %q, got %q (err=%v)", test.value,
+				test.expected, cmd, err)
+		}
+	}
+
+	_, err := cf.Command(Response{AID: AIDEnter,
+		Values: map[string]string{"option": "bogus"}})
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("expected ErrUnknownCommand, got %v", err)
+	}
+
+	// A PF key without a command is unknown, and the error names it
+	_, err = cf.Command(Response{AID: AIDPF9,
+		Values: map[string]string{"option": "exit"}})
+	if !errors.Is(err, ErrUnknownCommand) || !strings.HasSuffix(err.Error(),
+		": 9") {
+		t.Errorf("expected ErrUnknownCommand for 9, got %v", err)
+	}
+}
+
+func TestMenuChoice(t *testing.T) {
+	tests := []struct {
+		aid    AID
+		value  string
+		choice int
+		ok     bool
+	}{
+		{AIDEnter, "3", 3, true},
+		{AIDEnter, " 12 ", 12, true},
+		{AIDPF4, "", 4, true},
+		{AIDPF24, "3", 24, true}, // the PF key wins over the field
+		{AIDEnter, "", 0, false},
+		{AIDEnter, "0", 0, false},
+		{AIDEnter, "-1", 0, false},
+		{AIDEnter, "x", 0, false},
+		{AIDPA1, "3", 0, false},
+		{AIDClear, "3", 0, false},
+	}
+	for _, test := range tests {
+		resp := Response{AID: test.aid,
+			Values: map[string]string{"opt": test.value}}
+		choice, ok := MenuChoice(resp, "opt")
+		if choice != test.choice || ok != test.ok {
+			t.Errorf("%s %q: expected %d %v, got %d %v",
+				AIDtoString(test.aid), test.value, test.choice, test.ok,
+				choice, ok)
+		}
+	}
+}
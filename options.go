@@ -0,0 +1,104 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"time"
+)
+
+// ScreenOption sets an option on a ScreenOpts. ScreenOptions are passed to
+// NewScreenOpts() as an alternative to building the ScreenOpts struct
+// directly, e.g.:
+//
+//	ShowScreenOpts(screen, values, conn, NewScreenOpts(WithCursor(4, 20)))
+type ScreenOption func(*ScreenOpts)
+
+// NewScreenOpts returns a ScreenOpts with each of the options applied in
+// order.
+func NewScreenOpts(options ...ScreenOption) ScreenOpts {
+	var opts ScreenOpts
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// WithCursor places the cursor at the 0-based row and col.
+func WithCursor(row, col int) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.CursorRow = row
+		opts.CursorCol = col
+	}
+}
+
+// WithCursorField places the cursor offset positions into the named field.
+func WithCursorField(name string, offset int) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.CursorField = name
+		opts.CursorFieldOffset = offset
+	}
+}
+
+// WithCursorAfterField places the cursor just after the content of the named
+// field. See ScreenOpts.CursorAfterField.
+func WithCursorAfterField(name string) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.CursorAfterField = name
+	}
+}
+
+// WithClearFields clears the named fields before the screen is written. See
+// ScreenOpts.ClearFields.
+func WithClearFields(names ...string) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.ClearFields = append(opts.ClearFields, names...)
+	}
+}
+
+// WithEraseMode selects whether the screen is cleared before it is written.
+func WithEraseMode(mode EraseMode) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.EraseMode = mode
+	}
+}
+
+// WithWCC replaces the Write Control Character sent with the screen. See
+// ScreenOpts.WCCOverride.
+func WithWCC(wcc byte) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.WCCOverride = &wcc
+	}
+}
+
+// WithNoCursorMove leaves the cursor position up to the client. See
+// ScreenOpts.NoCursorMove.
+func WithNoCursorMove() ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.NoCursorMove = true
+	}
+}
+
+// WithScreenSize sets the size of the client's screen. See ScreenOpts.Rows.
+func WithScreenSize(rows, cols int) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.Rows, opts.Cols = rows, cols
+	}
+}
+
+// WithAlarm sounds the client's alarm when the screen is written. See
+// ScreenOpts.SoundAlarm.
+func WithAlarm() ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.SoundAlarm = true
+	}
+}
+
+// WithInputTimeout limits how long to wait for the user to respond. See
+// ScreenOpts.InputTimeout.
+func WithInputTimeout(timeout time.Duration) ScreenOption {
+	return func(opts *ScreenOpts) {
+		opts.InputTimeout = timeout
+	}
+}
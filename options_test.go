@@ -0,0 +1,79 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewScreenOpts(t *testing.T) {
+	wcc := byte(0xc3)
+	tests := []struct {
+		option   ScreenOption
+		expected ScreenOpts
+	}{
+		{WithCursor(4, 20), ScreenOpts{CursorRow: 4, CursorCol: 20}},
+		{WithCursorField("name", 2),
+			ScreenOpts{CursorField: "name", CursorFieldOffset: 2}},
+		{WithCursorAfterField("name"), ScreenOpts{CursorAfterField: "name"}},
+		{WithClearFields("a", "b"), ScreenOpts{ClearFields: []string{"a",
+			"b"}}},
+		{WithEraseMode(WriteOnly), ScreenOpts{EraseMode: WriteOnly}},
+		{WithWCC(wcc), ScreenOpts{WCCOverride: &wcc}},
+		{WithNoCursorMove(), ScreenOpts{NoCursorMove: true}},
+		{WithScreenSize(27, 132), ScreenOpts{Rows: 27, Cols: 132}},
+		{WithAlarm(), ScreenOpts{SoundAlarm: true}},
+		{WithInputTimeout(time.Minute), ScreenOpts{InputTimeout: time.Minute}},
+	}
+	for i, test := range tests {
+		if got := NewScreenOpts(test.option); !reflect.DeepEqual(got,
+			test.expected) {
+			t.Errorf("option %d: expected %+v, got %+v", i, test.expected,
+				got)
+		}
+	}
+
+	if got := NewScreenOpts(); !reflect.DeepEqual(got, ScreenOpts{}) {
+		t.Errorf("expected the zero ScreenOpts, got %+v", got)
+	}
+}
+
+func TestNewScreenOptsOrder(t *testing.T) {
+	// Later options override earlier ones
+	opts := NewScreenOpts(WithCursor(1, 2), WithCursor(3, 4),
+		WithCursorField("a", 1), WithCursorField("b", 0),
+		WithEraseMode(WriteOnly), WithEraseMode(EraseWrite),
+		WithWCC(0xc1), WithWCC(0xc2), WithScreenSize(43, 80),
+		WithScreenSize(27, 132), WithInputTimeout(time.Second),
+		WithInputTimeout(time.Minute))
+	if opts.CursorRow != 3 || opts.CursorCol != 4 {
+		t.Errorf("expected cursor (3,4), got (%d,%d)", opts.CursorRow,
+			opts.CursorCol)
+	}
+	if opts.CursorField != "b" || opts.CursorFieldOffset != 0 {
+		t.Errorf("expected cursor field b+0, got %s+%d", opts.CursorField,
+			opts.CursorFieldOffset)
+	}
+	if opts.EraseMode != EraseWrite {
+		t.Errorf("expected EraseWrite, got %v", opts.EraseMode)
+	}
+	if opts.WCCOverride == nil || *opts.WCCOverride != 0xc2 {
+		t.Errorf("expected WCC c2, got %v", opts.WCCOverride)
+	}
+	if opts.Rows != 27 || opts.Cols != 132 {
+		t.Errorf("expected 27x132, got %dx%d", opts.Rows, opts.Cols)
+	}
+	if opts.InputTimeout != time.Minute {
+		t.Errorf("expected a minute, got %v", opts.InputTimeout)
+	}
+
+	// except WithClearFields, which adds to the fields to clear
+	opts = NewScreenOpts(WithClearFields("a"), WithClearFields("b"))
+	if !reflect.DeepEqual(opts.ClearFields, []string{"a", "b"}) {
+		t.Errorf("expected a and b cleared, got %v", opts.ClearFields)
+	}
+}
@@ -0,0 +1,133 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+)
+
+// Pager displays lines of text a screenful at a time, in the style of TSO
+// and ISPF output browsing. The top row holds the title and a MORE...
+// indicator, or BOTTOM once the last line is displayed; the second row
+// holds a command line; the text follows. PF8 scrolls forward and PF7
+// scrolls back a page at a time, and the TOP and BOTTOM (or BOT) commands
+// go to the start and end of the text.
+type Pager struct {
+	Title string
+	Lines []string
+
+	// Top is the index in Lines of the first line displayed. It may be set
+	// before calling Run() to start somewhere other than the first line,
+	// and holds the position the user left the pager at after Run()
+	// returns.
+	Top int
+
+	// Height is the number of rows of text displayed, starting on the
+	// third row. The default leaves the last two rows of the screen for
+	// messages and the PF key legend: 20 rows on a 24x80 screen.
+	Height int
+
+	// IndicatorRow and IndicatorCol are the position of the MORE... and
+	// BOTTOM indicator. The default is the top right of the screen.
+	IndicatorRow int
+	IndicatorCol int
+
+	// ExitKeys are the keys that leave the pager. The default is PF3.
+	ExitKeys []AID
+
+	// Opts are the options used to display each page. The screen size
+	// set by Opts.ScreenOpts.Rows and Cols is used to lay out the pages,
+	// and the cursor is placed on the command line unless
+	// Opts.ScreenOpts.CursorField is set.
+	Opts HandleOpts
+}
+
+// Run displays the pager on conn until the user presses one of the exit
+// keys, and returns the response to the exit key.
+func (p *Pager) Run(conn Transport) (Response, error) {
+	// Each line displayed is the width of the screen after the attribute
+	size := p.Opts.ScreenOpts.size()
+	width := size.cols - 1
+	height := p.Height
+	if height <= 0 {
+		height = size.rows - 4
+	}
+	indicatorRow, indicatorCol := p.IndicatorRow, p.IndicatorCol
+	if indicatorRow == 0 && indicatorCol == 0 {
+		indicatorCol = size.cols - 10
+	}
+	exitKeys := p.ExitKeys
+	if exitKeys == nil {
+		exitKeys = []AID{AIDPF3}
+	}
+	bottom := len(p.Lines) - height
+	if bottom < 0 {
+		bottom = 0
+	}
+
+	values := make(map[string]string)
+	for {
+		if p.Top > bottom {
+			p.Top = bottom
+		}
+		if p.Top < 0 {
+			p.Top = 0
+		}
+
+		indicator := "MORE..."
+		if p.Top >= bottom {
+			indicator = "BOTTOM"
+		}
+		screen := Screen{
+			{Row: 0, Col: 0, Content: p.Title, Intense: true},
+			{Row: indicatorRow, Col: indicatorCol, Content: indicator,
+				Intense: true},
+			{Row: 1, Col: 0, Content: "Command ===>"},
+			{Row: 1, Col: 13, Name: "command", Write: true},
+			{Row: 1, Col: width},
+			{Row: height + 2, Col: 0, Name: "message", Intense: true},
+			{Row: height + 3, Col: 0,
+				Content: "F3=Exit  F7=Backward  F8=Forward"},
+		}
+		for i := 0; i < height && p.Top+i < len(p.Lines); i++ {
+			screen = append(screen, Field{Row: 2 + i, Col: 0,
+				Content: p.Lines[p.Top+i], MaxWidth: width})
+		}
+
+		values["command"] = ""
+		opts := p.Opts
+		if opts.CursorField == "" {
+			opts.CursorField = "command"
+		}
+		resp, err := HandleScreenOpts(screen, nil, values,
+			[]AID{AIDEnter, AIDPF7, AIDPF8}, exitKeys, "message", conn, opts)
+		if err != nil {
+			return resp, err
+		}
+		delete(values, "message")
+
+		switch resp.AID {
+		case AIDPF7:
+			p.Top -= height
+			continue
+		case AIDPF8:
+			p.Top += height
+			continue
+		case AIDEnter:
+		default:
+			return resp, nil
+		}
+
+		switch command := strings.ToUpper(resp.Values["command"]); command {
+		case "":
+		case "TOP":
+			p.Top = 0
+		case "BOTTOM", "BOT":
+			p.Top = bottom
+		default:
+			values["message"] = "Unknown command: " + command
+		}
+	}
+}
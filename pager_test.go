@@ -0,0 +1,84 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	inbound := []byte{
+		0xf8, 0x40, 0x40, 0xff, 0xef, // PF8
+		0xf8, 0x40, 0x40, 0xff, 0xef, // PF8, past the end
+		0xf7, 0x40, 0x40, 0xff, 0xef, // PF7
+		0x7d, 0x40, 0x40, 0x11} // Enter with "bot" in the command field
+	inbound = append(inbound, getpos(1, 14, defaultSize)...)
+	inbound = append(inbound, 0x82, 0x96, 0xa3, 0xff, 0xef)
+	inbound = append(inbound, 0xf3, 0x40, 0x40, 0xff, 0xef) // PF3
+	conn := &recordingTransport{in: bytes.NewReader(inbound)}
+
+	p := Pager{Title: "Test", Lines: []string{"L0", "L1", "L2", "L3", "L4"},
+		Height: 2}
+	resp, err := p.Run(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDPF3 {
+		t.Errorf("expected PF3, got %s", AIDtoString(resp.AID))
+	}
+	if p.Top != 3 {
+		t.Errorf("expected to finish at line 3, got %d", p.Top)
+	}
+
+	written := conn.written.Bytes()
+	if !bytes.Contains(written, a2e([]byte("BOTTOM"))) {
+		t.Error("expected the BOTTOM indicator to be displayed")
+	}
+	if !bytes.Contains(written, a2e([]byte("MORE..."))) {
+		t.Error("expected the MORE... indicator to be displayed")
+	}
+}
+
+func TestPagerScreenSize(t *testing.T) {
+	size := ScreenOpts{Rows: 27, Cols: 132}.size()
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0xf3, 0x40, 0x40, 0xff, 0xef})} // PF3
+
+	lines := []string{strings.Repeat("x", 200)}
+	for i := 1; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("LINE%02d", i))
+	}
+	p := Pager{Title: "Test", Lines: lines, Opts: HandleOpts{
+		ScreenOpts: ScreenOpts{Rows: 27, Cols: 132, CursorField: "message"}}}
+	if _, err := p.Run(conn); err != nil {
+		t.Fatal(err)
+	}
+	written := conn.written.Bytes()
+
+	// Lines use the full width, and the command line ends at its end
+	if !bytes.Contains(written, a2e([]byte(strings.Repeat("x", 131)))) ||
+		bytes.Contains(written, a2e([]byte(strings.Repeat("x", 132)))) {
+		t.Error("expected the long line truncated to 131 characters")
+	}
+	if !bytes.Contains(written, sba(1, 131, size)) {
+		t.Error("expected the command line's stop field at (1,131)")
+	}
+	if !bytes.Contains(written, sba(0, 122, size)) {
+		t.Error("expected the indicator at the top right")
+	}
+
+	// The default height leaves the last two rows, and the cursor is in
+	// the caller's field
+	if !bytes.Contains(written, a2e([]byte("LINE22"))) ||
+		bytes.Contains(written, a2e([]byte("LINE23"))) {
+		t.Error("expected 23 lines of text")
+	}
+	if !bytes.Contains(written, ic(25, 1, size)) {
+		t.Error("expected the cursor in the message field")
+	}
+}
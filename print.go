@@ -0,0 +1,103 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// ErrLineLength is returned by Print() for a PrintOpts.LineLength other
+// than 0, 40, 64, or 80.
+var ErrLineLength = errors.New(
+	"go3270: print line length must be 0, 40, 64, or 80")
+
+// PrintOpts are the options for printing a report with Print().
+type PrintOpts struct {
+	// StartPrint sets the start printer bit in the WCC, so the printer
+	// prints the data as soon as it is received. Without it, the data is
+	// only stored in the printer's buffer.
+	StartPrint bool
+
+	// LineLength selects the printout format in the WCC. With the default,
+	// 0, the printer formats the output with the NL, FF, and EM control
+	// characters Print() inserts. 40, 64, or 80 instead print lines of that
+	// fixed length: each line is padded or truncated to LineLength, and the
+	// control characters are not sent, so LinesPerPage is ignored.
+	LineLength int
+
+	// LinesPerPage, if greater than 0, starts a new page with a form feed
+	// after every LinesPerPage lines. Otherwise lines are only separated by
+	// new lines.
+	LinesPerPage int
+}
+
+// Print sends the lines of a report to a printer, such as a 3287 or an
+// emulated printer connected as a printer LU, with an Erase/Write command.
+// Print does not wait for a response; printers don't send one. Each call
+// must fit in the printer's buffer, normally 1920 characters including the
+// control characters, so send longer reports with several calls.
+func Print(conn Transport, lines []string, opts PrintOpts) error {
+	datastream, err := buildPrint(lines, opts)
+	if err != nil {
+		return err
+	}
+
+	debugf("sending print datastream: %x\n", datastream)
+	debugDatastream("", defaultSize, datastream)
+	return writeAll(conn, datastream)
+}
+
+// buildPrint returns the datastream to print lines, including the trailing
+// telnet EOR.
+func buildPrint(lines []string, opts PrintOpts) ([]byte, error) {
+	var wcc byte
+	switch opts.LineLength {
+	case 0:
+	case 40:
+		wcc |= 1 << 4 // "bits 2-3" = 01
+	case 64:
+		wcc |= 2 << 4 // "bits 2-3" = 10
+	case 80:
+		wcc |= 3 << 4 // "bits 2-3" = 11
+	default:
+		return nil, ErrLineLength
+	}
+	if opts.StartPrint {
+		wcc |= 1 << 3 // set "bit 4"
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(0xf5) // Erase/Write
+	b.WriteByte(codes[wcc])
+	b.Write(sba(0, 0, defaultSize))
+
+	for i, line := range lines {
+		if opts.LineLength > 0 {
+			if len(line) > opts.LineLength {
+				line = truncateContent(line, opts.LineLength)
+			}
+			line += strings.Repeat(" ", opts.LineLength-len(line))
+			b.Write(a2e([]byte(line)))
+			continue
+		}
+
+		b.Write(a2e([]byte(line)))
+		switch {
+		case i == len(lines)-1:
+		case opts.LinesPerPage > 0 && (i+1)%opts.LinesPerPage == 0:
+			b.WriteByte(EBCDICFF)
+		default:
+			b.WriteByte(EBCDICNL)
+		}
+	}
+	if opts.LineLength == 0 {
+		b.WriteByte(EBCDICEM)
+	}
+
+	// Escape any IAC bytes in the datastream, then add Telnet IAC EOR
+	return append(telnetEscape(b.Bytes()), iac, eor), nil
+}
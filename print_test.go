@@ -0,0 +1,37 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	conn := &recordingTransport{}
+	err := Print(conn, []string{"A", "B", "C"},
+		PrintOpts{StartPrint: true, LinesPerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0xf5, 0xc8, 0x11, 0x40, 0x40,
+		0xc1, EBCDICNL, 0xc2, EBCDICFF, 0xc3, EBCDICEM, iac, eor}
+	if !bytes.Equal(conn.written.Bytes(), expected) {
+		t.Errorf("expected %x, got %x", expected, conn.written.Bytes())
+	}
+
+	datastream, err := buildPrint([]string{"AB"}, PrintOpts{LineLength: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if datastream[1] != 0x50 || len(datastream) != 5+40+2 {
+		t.Errorf("unexpected formatted datastream %x", datastream)
+	}
+
+	if _, err := buildPrint(nil, PrintOpts{LineLength: 132}); err !=
+		ErrLineLength {
+		t.Errorf("expected ErrLineLength, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+)
+
+// ProgressBar is a progress bar drawn over the screen last sent to the
+// client, filled from the left in reverse video in proportion to a
+// percentage. Draw and redraw it with Update().
+//
+// The keyboard is locked while the progress bar is displayed, so the user
+// can't submit the screen during the operation; it is unlocked by the next
+// ShowScreen() or HandleScreen(). The rest of the screen is left unchanged.
+// Reverse video requires a client that supports extended highlighting.
+type ProgressBar struct {
+	// Row and Col are the position of the bar's field attribute. The bar
+	// itself occupies the following Width positions.
+	Row   int
+	Col   int
+	Width int
+
+	// Rows and Cols are the size of the client's screen, when it is set
+	// with ScreenOpts.Rows and ScreenOpts.Cols; they are used only when both
+	// are non-zero.
+	Rows int
+	Cols int
+}
+
+// Field returns the field for the progress bar filled to pct percent,
+// clamped to 0-100.
+func (p ProgressBar) Field(pct int) Field {
+	if pct < 0 {
+		pct = 0
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	filled := p.Width * pct / 100
+
+	var raw bytes.Buffer
+	raw.Write([]byte{0x28, 0x41, byte(ReverseVideo)}) // SA: reverse
+	raw.Write(bytes.Repeat([]byte{0x40}, filled))     // spaces
+	raw.Write([]byte{0x28, 0x41, 0x00})               // SA: default
+	raw.Write(bytes.Repeat([]byte{0x40}, p.Width-filled))
+
+	return Field{Row: p.Row, Col: p.Col, RawContent: raw.Bytes()}
+}
+
+// Update draws the progress bar on the client's screen filled to pct
+// percent, clamped to 0-100.
+func (p ProgressBar) Update(conn Transport, pct int) error {
+	size := ScreenOpts{Rows: p.Rows, Cols: p.Cols}.size()
+	return writeUpdate(Screen{p.Field(pct)}, nil, wccLocked, size, conn)
+}
@@ -0,0 +1,54 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	bar := ProgressBar{Row: 26, Col: 100, Width: 20, Rows: 27, Cols: 132}
+	reverse := []byte{0x28, 0x41, byte(ReverseVideo)}
+	normal := []byte{0x28, 0x41, 0x00}
+
+	for _, test := range []struct {
+		pct    int
+		filled int
+	}{{0, 0}, {50, 10}, {100, 20}, {-5, 0}, {150, 20}} {
+		conn := &recordingTransport{}
+		if err := bar.Update(conn, test.pct); err != nil {
+			t.Fatal(err)
+		}
+		written := conn.written.Bytes()
+
+		if !bytes.HasPrefix(written, []byte{0xf1, wccLocked}) {
+			t.Errorf("%d%%: expected a Write with the keyboard locked, got %x",
+				test.pct, written)
+		}
+		at := sba(26, 100, ScreenOpts{Rows: 27, Cols: 132}.size())
+		if !bytes.Contains(written, at) {
+			t.Errorf("%d%%: expected SBA %x, got %x", test.pct, at, written)
+		}
+
+		start := bytes.Index(written, reverse)
+		end := bytes.Index(written, normal)
+		if start < 0 || end < start {
+			t.Fatalf("%d%%: expected reverse then normal video, got %x",
+				test.pct, written)
+		}
+		fill := written[start+len(reverse) : end]
+		if len(fill) != test.filled ||
+			bytes.Count(fill, []byte{0x40}) != test.filled {
+			t.Errorf("%d%%: expected %d reverse video spaces, got %x",
+				test.pct, test.filled, fill)
+		}
+		rest := written[end+len(normal) : len(written)-2]
+		if len(rest) != 20-test.filled {
+			t.Errorf("%d%%: expected %d unfilled positions, got %d",
+				test.pct, 20-test.filled, len(rest))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+)
+
+// Buffer is the complete contents of the client's screen, as returned by
+// ReadBuffer().
+type Buffer struct {
+	// AID is the AID byte sent with the buffer, normally AIDNone.
+	AID AID
+
+	// CursorRow and CursorCol are the 0-based position of the cursor.
+	CursorRow int
+	CursorCol int
+
+	// Chars holds the character at each screen position, indexed as
+	// Chars[row][col]. Field attribute positions and null characters are
+	// spaces, as they appear on the screen.
+	Chars [][]rune
+
+	// Fields are the field attributes on the screen, in buffer order.
+	Fields []BufferField
+}
+
+// BufferField is a field attribute in a Buffer.
+type BufferField struct {
+	// Row and Col are the position of the field attribute.
+	Row int
+	Col int
+
+	// Attribute is the basic field attribute byte sent by the client. The
+	// remaining members of BufferField are decoded from it.
+	Attribute byte
+
+	Protected bool
+	Numeric   bool
+	Intense   bool
+	Hidden    bool
+	Modified  bool
+}
+
+// Line returns row of the buffer as a string.
+func (b Buffer) Line(row int) string {
+	if row < 0 || row >= len(b.Chars) {
+		return ""
+	}
+	return string(b.Chars[row])
+}
+
+// Text returns the length characters of the buffer starting at row, col,
+// continuing onto following rows if necessary.
+func (b Buffer) Text(row, col, length int) string {
+	var sb strings.Builder
+	for ; row < len(b.Chars) && length > 0; row, col = row+1, 0 {
+		for ; col < len(b.Chars[row]) && length > 0; col++ {
+			sb.WriteRune(b.Chars[row][col])
+			length--
+		}
+	}
+	return sb.String()
+}
+
+// ReadBuffer sends the 3270 Read Buffer command to the client and returns
+// the entire contents of its screen, including protected fields and
+// unmodified input fields, not only the modified fields a normal response
+// contains. This is useful for automated testing and screen-scraping.
+// rows and cols are the size of the client's screen; if either is 0, the
+// default 24x80 size is used.
+//
+// Like WaitForKey(), ReadBuffer must be called only when no screen is
+// waiting for a response.
+func ReadBuffer(conn Transport, rows, cols int) (Buffer, error) {
+	size := ScreenOpts{Rows: rows, Cols: cols}.size()
+
+	datastream := []byte{0xf2, iac, eor} // Read Buffer
+	debugf("sending read buffer datastream: %x\n", datastream)
+	if err := writeAll(conn, datastream); err != nil {
+		return Buffer{}, err
+	}
+
+	conn = newReadConn(conn, "")
+	var result Buffer
+	aid, err := readAID(conn)
+	if err != nil {
+		return result, err
+	}
+	result.AID = aid
+
+	if result.CursorRow, result.CursorCol, _, err = readPosition(conn,
+		size); err != nil {
+		return result, err
+	}
+
+	result.Chars = make([][]rune, size.rows)
+	for row := range result.Chars {
+		result.Chars[row] = []rune(strings.Repeat(" ", size.cols))
+	}
+
+	pos := 0
+	put := func(r rune) {
+		result.Chars[pos/size.cols][pos%size.cols] = r
+		pos = (pos + 1) % size.buffer()
+	}
+	next := func() (byte, bool, error) {
+		b, _, eor, err := telnetRead(conn, true)
+		return b, eor, err
+	}
+
+	for {
+		b, eor, err := next()
+		if err != nil {
+			return result, err
+		}
+		if eor {
+			return result, nil
+		}
+
+		switch b {
+		case 0x1d: // SF
+			attr, eor, err := next()
+			if err != nil || eor {
+				return result, err
+			}
+			result.Fields = append(result.Fields, bufferField(pos, attr, size))
+			put(' ')
+		case 0x29: // SFE
+			count, eor, err := next()
+			if err != nil || eor {
+				return result, err
+			}
+			var attr byte
+			for i := 0; i < int(count); i++ {
+				typ, _, err := next()
+				if err != nil {
+					return result, err
+				}
+				value, _, err := next()
+				if err != nil {
+					return result, err
+				}
+				if typ == 0xc0 {
+					attr = value
+				}
+			}
+			result.Fields = append(result.Fields, bufferField(pos, attr, size))
+			put(' ')
+		case 0x28: // SA: character attributes are not returned
+			for i := 0; i < 2; i++ {
+				if _, _, err := next(); err != nil {
+					return result, err
+				}
+			}
+		case 0x08: // GE: the next byte is a code page 310 character
+			c, _, err := next()
+			if err != nil {
+				return result, err
+			}
+			r, ok := graphicRunes[c]
+			if !ok {
+				r = '?'
+			}
+			put(r)
+		case 0x00:
+			put(' ')
+		default:
+			put(rune(ascii[b]))
+		}
+	}
+}
+
+// bufferField decodes the basic field attribute attr at the buffer address
+// addr.
+func bufferField(addr int, attr byte, size screenSize) BufferField {
+	return BufferField{
+		Row:       addr / size.cols,
+		Col:       addr % size.cols,
+		Attribute: attr,
+		Protected: attr&0x20 != 0,
+		Numeric:   attr&0x10 != 0,
+		Intense:   attr&0x0c == 0x08,
+		Hidden:    attr&0x0c == 0x0c,
+		Modified:  attr&0x01 != 0,
+	}
+}
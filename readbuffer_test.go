@@ -0,0 +1,68 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadBuffer(t *testing.T) {
+	// No AID, cursor at address 5, then a protected field "HI" and an
+	// unprotected intensified field containing a null and "A"
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x60, 0x40, 0xc5,
+		0x1d, 0x60, 0xc8, 0xc9,
+		0x29, 0x01, 0xc0, 0xc8, 0x00, 0xc1,
+		0xff, 0xef})}
+
+	buf, err := ReadBuffer(conn, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0xf2, iac, eor}; !bytes.Equal(
+		conn.written.Bytes(), expected) {
+		t.Errorf("expected %x to be sent, got %x", expected,
+			conn.written.Bytes())
+	}
+
+	if buf.AID != AIDNone || buf.CursorRow != 0 || buf.CursorCol != 5 {
+		t.Errorf("unexpected AID %02x or cursor (%d, %d)", buf.AID,
+			buf.CursorRow, buf.CursorCol)
+	}
+	if len(buf.Chars) != 24 || len(buf.Chars[23]) != 80 {
+		t.Errorf("expected a 24x80 buffer")
+	}
+	if line := buf.Text(0, 0, 7); line != " HI  A " {
+		t.Errorf("unexpected text %q", line)
+	}
+
+	if len(buf.Fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(buf.Fields))
+	}
+	if f := buf.Fields[0]; f.Col != 0 || !f.Protected || f.Intense {
+		t.Errorf("unexpected first field %+v", f)
+	}
+	if f := buf.Fields[1]; f.Col != 3 || f.Protected || !f.Intense {
+		t.Errorf("unexpected second field %+v", f)
+	}
+}
+
+func TestReadBufferGraphicEscape(t *testing.T) {
+	// A field holding the top line of a Box, as the client returns it
+	box := Box(1, 1, 2, 4)[0]
+	in := []byte{0x60, 0x40, 0x40, 0x1d, 0x60}
+	in = append(in, box.RawContent...)
+	in = append(in, 0x08, 0x01, iac, eor) // and an unknown character
+	conn := &recordingTransport{in: bytes.NewReader(in)}
+
+	buf, err := ReadBuffer(conn, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := buf.Text(0, 1, 5); line != "┌──┐?" {
+		t.Errorf("expected the box's line characters, got %q", line)
+	}
+}
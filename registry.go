@@ -0,0 +1,186 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShutdown is returned by ShowScreenOpts() and HandleScreenOpts() for
+// connections using a SessionRegistry that has been shut down.
+var ErrShutdown = errors.New("go3270: server is shutting down")
+
+// SessionRegistry keeps track of the connections that are waiting for the
+// user to respond to a screen, so that a server can notify every connected
+// user and disconnect them cleanly when it shuts down. Using a registry is
+// optional: set ScreenOpts.Registry on the screens displayed to each
+// connection to take part. The zero value is not usable; create a
+// SessionRegistry with NewSessionRegistry().
+type SessionRegistry struct {
+	mu       sync.Mutex
+	active   map[*registration]bool
+	shutdown bool
+	message  string
+
+	// deadline is the read deadline Shutdown() gives the waiting
+	// connections.
+	deadline time.Time
+}
+
+// registration is a screen waiting for a response, returned by wait(). It
+// is the key for the wait in SessionRegistry.active; Transports can't be
+// used as map keys, since an implementation need not be comparable.
+type registration struct {
+	registry *SessionRegistry
+	conn     Transport
+
+	// writing is held while Shutdown() writes its message to conn, and by
+	// done(), so the wait can't end and the handler write its next screen
+	// in the middle of the message.
+	writing sync.Mutex
+
+	// deadline is the read deadline the wait itself has set, protected by
+	// registry.mu.
+	deadline time.Time
+}
+
+// NewSessionRegistry creates an empty SessionRegistry.
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{active: make(map[*registration]bool)}
+}
+
+// Active returns the number of screens currently waiting for the user to
+// respond, which is normally one for each connection.
+func (r *SessionRegistry) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.active)
+}
+
+// Shutdown displays message, with the keyboard locked, on every connection
+// waiting for a response, and unblocks their reads once grace has passed so
+// the pending ShowScreenOpts() and HandleScreenOpts() calls return
+// ErrShutdown. The grace period gives users time to read the message. Any
+// later screens displayed with the registry return ErrShutdown after
+// showing the message instead of waiting for the user. Shutdown does not
+// close the connections; that remains the job of each connection's
+// handler.
+//
+// The message is written to each connection by Shutdown's own goroutine.
+// A screen's wait doesn't end until the message has been written to its
+// connection, so the handler's next screen can't be interleaved with it,
+// and writes to a ScreenSession's connection are serialized with the
+// session's. Shutdown is not coordinated with other writers, so don't write
+// to the connections from other goroutines, such as with
+// StatusLine.Update(), while Shutdown is running, or the datastreams may be
+// interleaved.
+func (r *SessionRegistry) Shutdown(message string, grace time.Duration) {
+	r.mu.Lock()
+	r.shutdown = true
+	r.message = message
+	r.deadline = time.Now().Add(grace)
+	regs := make([]*registration, 0, len(r.active))
+	for reg := range r.active {
+		regs = append(regs, reg)
+	}
+	r.mu.Unlock()
+
+	for _, reg := range regs {
+		r.writeShutdown(reg, message)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, reg := range regs {
+		if r.active[reg] {
+			reg.conn.SetReadDeadline(r.earliest(reg.deadline))
+		}
+	}
+}
+
+// wait registers conn as waiting for a response, returning the
+// registration to pass to done(). If the registry has been shut down, the
+// shutdown message is written to conn instead and ErrShutdown is returned.
+func (r *SessionRegistry) wait(conn Transport) (*registration, error) {
+	r.mu.Lock()
+	if r.shutdown {
+		message := r.message
+		r.mu.Unlock()
+		writeShutdownMessage(message, conn)
+		return nil, ErrShutdown
+	}
+	reg := &registration{registry: r, conn: conn}
+	r.active[reg] = true
+	r.mu.Unlock()
+	return reg, nil
+}
+
+// done removes a registration made by wait, waiting for Shutdown() to
+// finish writing to its connection if it is. It returns true if the
+// registry has been shut down in the meantime.
+func (r *SessionRegistry) done(reg *registration) bool {
+	reg.writing.Lock()
+	defer reg.writing.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.active, reg)
+	return r.shutdown
+}
+
+// writeShutdown writes the shutdown message to the registration's
+// connection, unless its wait has already ended. The write is serialized
+// with the writes of the connection's ScreenSession, if it has one.
+func (r *SessionRegistry) writeShutdown(reg *registration, message string) {
+	reg.writing.Lock()
+	defer reg.writing.Unlock()
+	r.mu.Lock()
+	active := r.active[reg]
+	r.mu.Unlock()
+	if !active {
+		return
+	}
+
+	if rc, ok := reg.conn.(*readConn); ok && rc.mu != nil {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+	}
+	// Errors are ignored: the connection is going away regardless.
+	writeShutdownMessage(message, reg.conn)
+}
+
+// setDeadline sets the read deadline of the registration's connection to t,
+// or to the shutdown deadline if the registry has been shut down and that is
+// earlier. Deadlines are set with the registry locked, so Shutdown() and the
+// wait can't overwrite each other's.
+func (reg *registration) setDeadline(t time.Time) error {
+	r := reg.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg.deadline = t
+	return reg.conn.SetReadDeadline(r.earliest(t))
+}
+
+// earliest returns the earlier of t and the shutdown deadline, if the
+// registry has been shut down. A zero t is no deadline. r.mu must be held.
+func (r *SessionRegistry) earliest(t time.Time) time.Time {
+	if r.shutdown && (t.IsZero() || r.deadline.Before(t)) {
+		return r.deadline
+	}
+	return t
+}
+
+// writeShutdownMessage erases the screen and displays message on the first
+// line, leaving the keyboard locked.
+func writeShutdownMessage(message string, conn Transport) error {
+	wcc := byte(wccLocked)
+	datastream, _ := buildDatastream(
+		Screen{{Row: 0, Col: 0, Intense: true, Content: message}}, nil,
+		ScreenOpts{WCCOverride: &wcc})
+	debugf("sending shutdown datastream: %x\n", datastream)
+	debugDatastream("", defaultSize, datastream)
+	return writeAll(conn, datastream)
+}
@@ -0,0 +1,232 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistryShutdown(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	// Collect everything the server sends to the client.
+	received := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(client)
+		received <- b
+	}()
+
+	r := NewSessionRegistry()
+	result := make(chan error)
+	go func() {
+		_, err := ShowScreenOpts(Screen{{Row: 1, Col: 1, Content: "hi"}},
+			nil, server, ScreenOpts{Registry: r})
+		result <- err
+	}()
+
+	for i := 0; r.Active() != 1; i++ {
+		if i == 100 {
+			t.Fatal("connection was never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r.Shutdown("GOING DOWN", 10*time.Millisecond)
+	if err := <-result; err != ErrShutdown {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+	if r.Active() != 0 {
+		t.Errorf("expected no active connections, got %d", r.Active())
+	}
+
+	// Screens displayed after shutdown return immediately.
+	_, err := ShowScreenOpts(Screen{{Row: 1, Col: 1, Content: "hi"}},
+		nil, server, ScreenOpts{Registry: r})
+	if err != ErrShutdown {
+		t.Errorf("expected ErrShutdown after shutdown, got %v", err)
+	}
+
+	server.Close()
+	message := a2e([]byte("GOING DOWN"))
+	if n := bytes.Count(<-received, message); n != 2 {
+		t.Errorf("expected the message to be sent twice, found %d", n)
+	}
+}
+
+// taggedTransport is a Transport that is not comparable, so it can't be a
+// map key.
+type taggedTransport struct {
+	*recordingTransport
+	tags []string
+}
+
+func TestRegistryNonComparableTransport(t *testing.T) {
+	conn := taggedTransport{recordingTransport: &recordingTransport{
+		in: bytes.NewReader([]byte{0x7d, 0x40, 0x40, iac, eor})},
+		tags: []string{"test"}}
+
+	r := NewSessionRegistry()
+	resp, err := ShowScreenOpts(Screen{{Row: 1, Col: 1, Content: "hi"}},
+		nil, conn, ScreenOpts{Registry: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter {
+		t.Errorf("expected Enter, got %s", AIDtoString(resp.AID))
+	}
+	if r.Active() != 0 {
+		t.Errorf("expected no active screens, got %d", r.Active())
+	}
+}
+
+// deadlineTransport is a Transport that records its read deadline.
+type deadlineTransport struct {
+	*recordingTransport
+	deadline time.Time
+}
+
+func (t *deadlineTransport) SetReadDeadline(d time.Time) error {
+	t.deadline = d
+	return nil
+}
+
+func TestRegistryShutdownDeadline(t *testing.T) {
+	conn := &deadlineTransport{recordingTransport: &recordingTransport{}}
+	r := NewSessionRegistry()
+	reg, err := r.wait(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A deadline set by the wait after Shutdown() can't postpone the
+	// shutdown deadline, but an earlier one is kept.
+	r.Shutdown("GOING DOWN", time.Minute)
+	shutdown := conn.deadline
+	if shutdown.IsZero() {
+		t.Fatal("expected Shutdown to set a deadline")
+	}
+	reg.setDeadline(time.Now().Add(time.Hour))
+	if !conn.deadline.Equal(shutdown) {
+		t.Errorf("expected the shutdown deadline %v, got %v", shutdown,
+			conn.deadline)
+	}
+	early := time.Now().Add(time.Second)
+	reg.setDeadline(early)
+	if !conn.deadline.Equal(early) {
+		t.Errorf("expected the earlier deadline %v, got %v", early,
+			conn.deadline)
+	}
+	if !r.done(reg) {
+		t.Error("expected done to report the shutdown")
+	}
+}
+
+// pausingTransport is a Transport whose reads come from a channel and whose
+// writes of a datastream containing pause block until release is closed.
+type pausingTransport struct {
+	in      chan []byte
+	buf     []byte
+	pause   []byte
+	paused  chan struct{}
+	release chan struct{}
+}
+
+func (t *pausingTransport) Read(b []byte) (int, error) {
+	if len(t.buf) == 0 {
+		t.buf = <-t.in
+	}
+	n := copy(b, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+func (t *pausingTransport) Write(b []byte) (int, error) {
+	if bytes.Contains(b, t.pause) {
+		close(t.paused)
+		<-t.release
+	}
+	return len(b), nil
+}
+
+func (t *pausingTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func TestRegistryShutdownWriteFinishes(t *testing.T) {
+	conn := &pausingTransport{in: make(chan []byte, 1),
+		pause: a2e([]byte("GOING DOWN")), paused: make(chan struct{}),
+		release: make(chan struct{})}
+
+	r := NewSessionRegistry()
+	result := make(chan error)
+	go func() {
+		_, err := ShowScreenOpts(Screen{{Row: 1, Col: 1, Content: "hi"}},
+			nil, conn, ScreenOpts{Registry: r})
+		result <- err
+	}()
+	for i := 0; r.Active() != 1; i++ {
+		if i == 100 {
+			t.Fatal("connection was never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	go r.Shutdown("GOING DOWN", time.Minute)
+	<-conn.paused
+
+	// The user responds while the shutdown message is being written, but
+	// the wait can't end until the write is complete.
+	conn.in <- []byte{0x7d, 0x40, 0x40, iac, eor}
+	select {
+	case err := <-result:
+		t.Fatalf("returned during the shutdown write: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(conn.release)
+	if err := <-result; err != ErrShutdown {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+}
+
+// shutdownTransport is a deadlineTransport that shuts the registry down
+// when it is first read from.
+type shutdownTransport struct {
+	*deadlineTransport
+	registry *SessionRegistry
+	once     sync.Once
+}
+
+func (t *shutdownTransport) Read(b []byte) (int, error) {
+	t.once.Do(func() { t.registry.Shutdown("GOING DOWN", time.Minute) })
+	return t.deadlineTransport.Read(b)
+}
+
+func TestRegistryShutdownDeadlineCleared(t *testing.T) {
+	r := NewSessionRegistry()
+	conn := &shutdownTransport{deadlineTransport: &deadlineTransport{
+		recordingTransport: &recordingTransport{
+			in: bytes.NewReader([]byte{0x7d, 0x40, 0x40, iac, eor})}},
+		registry: r}
+
+	// Shutdown() sets a deadline while the screen waits, which mustn't be
+	// left behind on the connection.
+	_, err := ShowScreenOpts(Screen{{Row: 1, Col: 1, Content: "hi"}}, nil,
+		conn, ScreenOpts{Registry: r})
+	if err != ErrShutdown {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+	if !conn.deadline.IsZero() {
+		t.Errorf("expected the deadline to be cleared, got %v",
+			conn.deadline)
+	}
+}
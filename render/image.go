@@ -0,0 +1,120 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package render
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/racingmars/go3270"
+)
+
+// Each cell is drawn with an 8x8 glyph doubled vertically.
+const (
+	cellWidth  = 8
+	cellHeight = 16
+)
+
+var background = color.RGBA{0x00, 0x00, 0x00, 0xff}
+
+// palette holds the RGB values used for the 3270 extended colors.
+var palette = map[go3270.Color]color.RGBA{
+	go3270.Blue:      {0x40, 0x80, 0xff, 0xff},
+	go3270.Red:       {0xff, 0x00, 0x00, 0xff},
+	go3270.Pink:      {0xff, 0x00, 0xff, 0xff},
+	go3270.Green:     {0x00, 0xff, 0x00, 0xff},
+	go3270.Turquoise: {0x00, 0xff, 0xff, 0xff},
+	go3270.Yellow:    {0xff, 0xff, 0x00, 0xff},
+	go3270.White:     {0xff, 0xff, 0xff, 0xff},
+}
+
+// RenderImage draws the screen as it would appear on a rows x cols color
+// 3270 terminal. Fields without an explicit Color are drawn in the
+// base-color-mode colors: protected fields are blue (white when intense) and
+// writable fields are green (red when intense). Blinking fields are drawn
+// without blinking.
+func RenderImage(screen go3270.Screen, values map[string]string,
+	rows, cols int) image.Image {
+
+	img := image.NewRGBA(image.Rect(0, 0, cols*cellWidth, rows*cellHeight))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+
+	for r, row := range Layout(screen, values, rows, cols) {
+		for c, cell := range row {
+			if cell.Attribute || cell.Field == nil {
+				continue
+			}
+			drawCell(img, c*cellWidth, r*cellHeight, cell)
+		}
+	}
+
+	return img
+}
+
+// drawCell draws a single non-attribute cell with its upper-left corner at
+// x, y.
+func drawCell(img *image.RGBA, x, y int, cell Cell) {
+	fg := fieldColor(cell.Field)
+	bg := background
+	if cell.Field.Highlighting == go3270.ReverseVideo {
+		fg, bg = bg, fg
+	}
+
+	glyph := glyphFor(cell.Rune)
+	if cell.Field.Hidden {
+		glyph = glyphFor(' ')
+	}
+
+	for gy := 0; gy < cellHeight; gy++ {
+		bits := glyph[gy/2]
+		if gy == cellHeight-1 &&
+			cell.Field.Highlighting == go3270.Underscore {
+			bits = 0xff
+		}
+		for gx := 0; gx < cellWidth; gx++ {
+			if bits&(1<<uint(gx)) != 0 {
+				img.SetRGBA(x+gx, y+gy, fg)
+			} else {
+				img.SetRGBA(x+gx, y+gy, bg)
+			}
+		}
+	}
+}
+
+// fieldColor returns the foreground color for text in the field.
+func fieldColor(f *go3270.Field) color.RGBA {
+	if c, ok := palette[f.Color]; ok {
+		return c
+	}
+	switch {
+	case f.Write && f.Intense:
+		return palette[go3270.Red]
+	case f.Write:
+		return palette[go3270.Green]
+	case f.Intense:
+		return palette[go3270.White]
+	default:
+		return palette[go3270.Blue]
+	}
+}
+
+// glyphFor returns the bitmap for a character, substituting '?' for
+// characters the bundled font doesn't have.
+func glyphFor(r rune) [8]byte {
+	if r == 0 {
+		r = ' '
+	}
+	if glyph, ok := boxGlyphs[r]; ok {
+		return glyph
+	}
+	if r < firstGlyph || int(r-firstGlyph) >= len(font) {
+		r = '?'
+	}
+	return font[r-firstGlyph]
+}
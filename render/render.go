@@ -0,0 +1,82 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+// Package render draws go3270 Screens without a 3270 client, for
+// documentation and testing purposes. It is kept separate from the go3270
+// package so that servers that don't need it don't pull in its
+// dependencies.
+package render
+
+import (
+	"github.com/racingmars/go3270"
+)
+
+// Cell is a single position in the 3270 presentation buffer.
+type Cell struct {
+	// Rune is the character in the cell, or 0 if the cell is null (was never
+	// written to).
+	Rune rune
+
+	// Attribute is true when the cell holds a field attribute. Field
+	// attribute cells are displayed as blanks by 3270 clients.
+	Attribute bool
+
+	// Field is the field that the cell belongs to: the nearest field
+	// attribute at or before this cell, wrapping around from the end of the
+	// buffer. Field is nil if there are no fields on the screen.
+	Field *go3270.Field
+}
+
+// Layout places the screen's fields into a rows x cols grid the way a 3270
+// client would lay out the datastream sent by go3270.ShowScreen(). Named
+// fields with an entry in values use that value instead of their Content,
+// as in ShowScreen(), and RawContent is decoded as described for
+// go3270.Field.DisplayRunes(). Fields positioned outside of the grid are
+// ignored.
+func Layout(screen go3270.Screen, values map[string]string,
+	rows, cols int) [][]Cell {
+
+	size := rows * cols
+	buffer := make([]Cell, size)
+
+	for i := range screen {
+		fld := &screen[i]
+		if fld.Row < 0 || fld.Row >= rows || fld.Col < 0 || fld.Col >= cols {
+			continue
+		}
+
+		addr := fld.Row*cols + fld.Col
+		buffer[addr] = Cell{Attribute: true, Field: fld}
+
+		// Content is written to the buffer starting after the attribute,
+		// wrapping at the end of each row and the end of the buffer. Like on
+		// a real terminal, characters overwrite anything already there.
+		for _, r := range fld.DisplayRunes(values) {
+			addr = (addr + 1) % size
+			buffer[addr] = Cell{Rune: r}
+		}
+	}
+
+	// Now that all attributes are in place, find the field that each
+	// non-attribute cell belongs to.
+	var current *go3270.Field
+	for i := size - 1; i >= 0 && current == nil; i-- {
+		if buffer[i].Attribute {
+			current = buffer[i].Field
+		}
+	}
+	for i := range buffer {
+		if buffer[i].Attribute {
+			current = buffer[i].Field
+			continue
+		}
+		buffer[i].Field = current
+	}
+
+	grid := make([][]Cell, rows)
+	for r := range grid {
+		grid[r] = buffer[r*cols : (r+1)*cols]
+	}
+	return grid
+}
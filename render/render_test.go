@@ -0,0 +1,208 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/racingmars/go3270"
+)
+
+func TestLayout(t *testing.T) {
+	screen := go3270.Screen{
+		{Row: 0, Col: 0, Content: "AB"},
+		{Row: 1, Col: 78, Content: "XYZ", Write: true},
+		{Row: 5, Col: 0, Content: "off the grid"},
+	}
+	grid := Layout(screen, nil, 3, 80)
+
+	if len(grid) != 3 || len(grid[0]) != 80 {
+		t.Fatalf("expected a 3x80 grid, got %dx%d", len(grid), len(grid[0]))
+	}
+	if !grid[0][0].Attribute || grid[0][0].Field != &screen[0] {
+		t.Error("expected the first field's attribute at 0,0")
+	}
+	if grid[0][1].Rune != 'A' || grid[0][2].Rune != 'B' {
+		t.Errorf("expected AB after the attribute, got %q%q",
+			grid[0][1].Rune, grid[0][2].Rune)
+	}
+	if grid[0][3].Rune != 0 || grid[0][3].Field != &screen[0] {
+		t.Error("expected a null cell belonging to the first field at 0,3")
+	}
+	if !grid[1][78].Attribute || grid[1][78].Field != &screen[1] {
+		t.Error("expected the second field's attribute at 1,78")
+	}
+
+	// Content wraps from the end of one row to the start of the next
+	if grid[1][79].Rune != 'X' || grid[2][0].Rune != 'Y' ||
+		grid[2][1].Rune != 'Z' {
+		t.Errorf("expected XYZ wrapped onto row 2, got %q %q%q",
+			grid[1][79].Rune, grid[2][0].Rune, grid[2][1].Rune)
+	}
+	if grid[2][0].Field != &screen[1] {
+		t.Error("expected wrapped content to belong to the second field")
+	}
+}
+
+func TestLayoutWrapBuffer(t *testing.T) {
+	screen := go3270.Screen{{Row: 0, Col: 2, Content: "abc"}}
+	grid := Layout(screen, nil, 1, 4)
+
+	// Content wraps from the end of the buffer to its start, and the cells
+	// before the only attribute belong to its field.
+	want := []rune{'b', 'c', 0, 'a'}
+	for i, r := range want {
+		if grid[0][i].Rune != r {
+			t.Errorf("cell %d: expected %q, got %q", i, r, grid[0][i].Rune)
+		}
+		if grid[0][i].Field != &screen[0] {
+			t.Errorf("cell %d: expected the cell to belong to the field", i)
+		}
+	}
+	if !grid[0][2].Attribute {
+		t.Error("expected the attribute at column 2")
+	}
+}
+
+func TestLayoutValues(t *testing.T) {
+	screen := go3270.Screen{{Row: 0, Col: 0, Name: "msg", Content: "old"}}
+	grid := Layout(screen, map[string]string{"msg": "new"}, 1, 10)
+	if got := string([]rune{grid[0][1].Rune, grid[0][2].Rune,
+		grid[0][3].Rune}); got != "new" {
+		t.Errorf("expected the override value, got %q", got)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	screen := go3270.Screen{
+		{Row: 0, Col: 2, Content: "Hello"},
+		{Row: 1, Col: 0, Content: "secret", Hidden: true},
+	}
+	want := "   Hello\n\n"
+	if got := RenderText(screen, nil, 2, 10); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderImage(t *testing.T) {
+	screen := go3270.Screen{{Row: 0, Col: 0, Content: "!"}}
+	img := RenderImage(screen, nil, 1, 2)
+
+	if b := img.Bounds(); b.Dx() != 2*cellWidth || b.Dy() != cellHeight {
+		t.Fatalf("expected a %dx%d image, got %dx%d", 2*cellWidth,
+			cellHeight, b.Dx(), b.Dy())
+	}
+
+	// The first row of the '!' glyph is 0x18: pixels 3 and 4 set. Each
+	// glyph row is drawn twice, and the glyph is in the second cell.
+	fg := palette[go3270.Blue]
+	for _, y := range []int{0, 1} {
+		for x := 0; x < cellWidth; x++ {
+			want := background
+			if x == 3 || x == 4 {
+				want = fg
+			}
+			if got := img.At(cellWidth+x, y); got != color.Color(want) {
+				t.Errorf("pixel %d,%d: expected %v, got %v", cellWidth+x,
+					y, want, got)
+			}
+		}
+	}
+
+	// The attribute cell is blank
+	for x := 0; x < cellWidth; x++ {
+		if got := img.At(x, 0); got != color.Color(background) {
+			t.Errorf("pixel %d,0: expected background, got %v", x, got)
+		}
+	}
+}
+
+func TestRenderTextAttributeChar(t *testing.T) {
+	screen := go3270.Screen{
+		{Row: 0, Col: 0, Content: "Name"},
+		{Row: 0, Col: 5, Name: "name", Write: true},
+		{Row: 0, Col: 10},
+	}
+
+	want := "^Name^    ^\n"
+	got := RenderTextOpts(screen, nil, 1, 12, TextOpts{AttributeChar: '^'})
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// By default attribute positions are blank, as on a terminal
+	want = " Name\n"
+	if got := RenderText(screen, nil, 1, 12); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestLayoutRawContent(t *testing.T) {
+	// EBCDIC "HI", a new line control character, a Set Attribute order
+	// (no position), and a graphic escape upper left corner.
+	screen := go3270.Screen{{Row: 0, Col: 0,
+		RawContent: []byte{0xc8, 0xc9, 0x15, 0x28, 0x42, 0xf2, 0x08, 0xc5},
+		Content:    "ignored"}}
+	grid := Layout(screen, nil, 1, 10)
+
+	want := []rune{'H', 'I', 0, '┌', 0}
+	for i, r := range want {
+		if got := grid[0][i+1].Rune; got != r {
+			t.Errorf("cell %d: expected %q, got %q", i+1, r, got)
+		}
+	}
+}
+
+func TestRenderTextMaxWidth(t *testing.T) {
+	screen := go3270.Screen{
+		{Row: 0, Col: 0, Name: "desc", MaxWidth: 5},
+		{Row: 0, Col: 20, Content: "next"},
+	}
+	values := map[string]string{"desc": "a long description"}
+
+	// Only the content ShowScreen() sends is rendered
+	want := " a lon               next\n"
+	if got := RenderText(screen, values, 1, 80); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderBox(t *testing.T) {
+	screen := go3270.Box(0, 1, 3, 4)
+
+	want := " ┌──┐\n │  │\n └──┘\n"
+	if got := RenderText(screen, nil, 3, 6); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	want = " +--+\n |  |\n +--+\n"
+	got := RenderTextOpts(screen, nil, 3, 6, TextOpts{NoExtended: true})
+	if got != want {
+		t.Errorf("expected fallback %q, got %q", want, got)
+	}
+
+	// The horizontal line is drawn through the middle of the cell
+	img := RenderImage(screen, nil, 3, 6)
+	fg := palette[go3270.Blue]
+	for y, want := range map[int]color.RGBA{0: background, 6: fg, 9: fg,
+		10: background} {
+		if got := img.At(2*cellWidth, y); got != color.Color(want) {
+			t.Errorf("pixel %d,%d: expected %v, got %v", 2*cellWidth, y,
+				want, got)
+		}
+	}
+}
+
+func TestRenderBoxInnerField(t *testing.T) {
+	screen := append(go3270.Box(0, 1, 3, 10),
+		go3270.Field{Row: 1, Col: 2, Content: "hi"})
+
+	// The inner field's attribute is inside the left side, which survives
+	want := " ┌────────┐\n │ hi     │\n └────────┘\n"
+	if got := RenderText(screen, nil, 3, 11); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,66 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package render
+
+import (
+	"strings"
+
+	"github.com/racingmars/go3270"
+)
+
+// TextOpts are the options for RenderTextOpts().
+type TextOpts struct {
+	// AttributeChar, if not 0, is displayed in field attribute positions
+	// instead of a space, to show where each field begins when debugging
+	// layouts. It does not affect what is sent to a 3270 client.
+	AttributeChar rune
+
+	// NoExtended renders the screen as it is displayed by a client without
+	// extended attribute support, as go3270.ScreenOpts.NoExtended does:
+	// fields with a Fallback are rendered as their fallback instead (see
+	// go3270.Screen.Resolve()).
+	NoExtended bool
+}
+
+// RenderText returns the screen as it would appear on a rows x cols 3270
+// terminal, as plain text with one line per row. Field attribute cells, null
+// cells, and the content of hidden fields are rendered as spaces. Trailing
+// spaces are removed from each line so the output is stable and easy to read
+// in text fixtures.
+func RenderText(screen go3270.Screen, values map[string]string,
+	rows, cols int) string {
+
+	return RenderTextOpts(screen, values, rows, cols, TextOpts{})
+}
+
+// RenderTextOpts renders the screen as text like RenderText(), with the
+// additional options in opts.
+func RenderTextOpts(screen go3270.Screen, values map[string]string,
+	rows, cols int, opts TextOpts) string {
+
+	if opts.NoExtended {
+		screen = screen.Resolve(go3270.ScreenOpts{NoExtended: true})
+	}
+
+	var sb strings.Builder
+	for _, row := range Layout(screen, values, rows, cols) {
+		line := make([]rune, len(row))
+		for i, cell := range row {
+			line[i] = ' '
+			if cell.Attribute && opts.AttributeChar != 0 {
+				line[i] = opts.AttributeChar
+				continue
+			}
+			if cell.Attribute || cell.Rune == 0 ||
+				(cell.Field != nil && cell.Field.Hidden) {
+				continue
+			}
+			line[i] = cell.Rune
+		}
+		sb.WriteString(strings.TrimRight(string(line), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
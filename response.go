@@ -7,7 +7,6 @@ package go3270
 import (
 	"bytes"
 	"fmt"
-	"net"
 	"os"
 )
 
@@ -25,6 +24,41 @@ type Response struct {
 
 	// Field values.
 	Values map[string]string
+
+	// CursorField is the name of the writable field the cursor was in, or
+	// "" if the cursor was not in a writable field (or the user pressed
+	// Clear or a PA key, which don't send the cursor position).
+	CursorField string
+
+	// PenSelections are the names of the detectable fields (see
+	// Field.PenDesignator) that were selected with the light pen or cursor
+	// select key, in the order the fields appear in the screen.
+	PenSelections []string
+
+	// TriggerField is the name of the field whose value was sent when AID
+	// is AIDTrigger (see Field.Trigger).
+	TriggerField string
+
+	// FieldAddresses maps the buffer address (row*80 + col, or with
+	// ScreenOpts.Cols set, row*Cols + col) of the first character of each
+	// writable field on the screen, just after its field attribute, to the
+	// field's name. It is the map go3270 uses to match the client's
+	// response to the screen's fields, and may be used to correlate
+	// positions to fields in the same way.
+	FieldAddresses map[int]string
+
+	// RawFields maps the buffer address of the first character of every
+	// field the client sent (with the same addressing as FieldAddresses) to
+	// the field's value, whether or not the field is named in the screen.
+	// This is useful for screens generated dynamically, where not every
+	// field is known in advance. Values have nulls removed as for Values,
+	// but are not trimmed of spaces.
+	RawFields map[int]string
+
+	// Changed reports, for each field in Values, whether the value returned
+	// by the client differs from the value that was sent. Changed is only
+	// populated by ScreenSession; it is nil for responses from ShowScreen().
+	Changed map[string]bool
 }
 
 // AID is an Action ID character.
@@ -61,9 +95,47 @@ const (
 	AIDPA2   AID = 0x6E
 	AIDPA3   AID = 0x6B
 	AIDClear AID = 0x6D
+
+	// AIDSelectorPen is sent when the user selects a field with the
+	// PenAttention designator.
+	AIDSelectorPen AID = 0x7E
+
+	// AIDTrigger is sent by the client, without the user pressing a key,
+	// when the user leaves a modified field that has Field.Trigger set.
+	AIDTrigger AID = 0x7F
 )
 
-func readResponse(c net.Conn, fm fieldmap) (Response, error) {
+// PFNumber returns the number, 1-24, of the PF key the AID represents, or 0
+// if the AID is not a PF key.
+func (a AID) PFNumber() int {
+	switch {
+	case a >= AIDPF1 && a <= AIDPF9:
+		return int(a-AIDPF1) + 1
+	case a >= AIDPF10 && a <= AIDPF12:
+		return int(a-AIDPF10) + 10
+	case a >= AIDPF13 && a <= AIDPF21:
+		return int(a-AIDPF13) + 13
+	case a >= AIDPF22 && a <= AIDPF24:
+		return int(a-AIDPF22) + 22
+	default:
+		return 0
+	}
+}
+
+// FieldAddress returns the buffer address of the first character of the
+// writable field with the given name, from FieldAddresses. If more than one
+// field has the name, the lowest address is returned. ok is false if there
+// is no such field.
+func (r Response) FieldAddress(name string) (addr int, ok bool) {
+	for a, n := range r.FieldAddresses {
+		if n == name && (!ok || a < addr) {
+			addr, ok = a, true
+		}
+	}
+	return addr, ok
+}
+
+func readResponse(c Transport, fm fieldmap, opts ScreenOpts) (Response, error) {
 	var r Response
 	aid, err := readAID(c)
 	if err != nil {
@@ -76,10 +148,13 @@ func readResponse(c net.Conn, fm fieldmap) (Response, error) {
 	// currently get taken care of in our next AID search.
 	if r.AID == AIDClear || r.AID == AIDPA1 || r.AID == AIDPA2 ||
 		r.AID == AIDPA3 {
+		if opts.ConsumeShortReads {
+			return r, consumeRecord(c)
+		}
 		return r, nil
 	}
 
-	row, col, _, err := readPosition(c)
+	row, col, _, err := readPosition(c, opts.size())
 	if err != nil {
 		return r, err
 	}
@@ -87,16 +162,41 @@ func readResponse(c net.Conn, fm fieldmap) (Response, error) {
 	r.Row = row
 
 	var fieldValues map[string]string
-	if fieldValues, err = readFields(c, fm); err != nil {
+	var first string
+	fieldValues, r.RawFields, first, err = readFields(c, fm, opts)
+	if err != nil {
 		return r, err
 	}
 
 	r.Values = fieldValues
 
+	// A trigger response carries only the triggering field; should a
+	// client send more, the first one is taken.
+	if r.AID == AIDTrigger {
+		r.TriggerField = first
+	}
+
 	return r, nil
 }
 
-func readAID(c net.Conn) (AID, error) {
+// consumeRecord reads and discards data from c up to and including the next
+// telnet EOR.
+func consumeRecord(c Transport) error {
+	for {
+		b, valid, eor, err := telnetRead(c, true)
+		if err != nil {
+			return err
+		}
+		if eor {
+			return nil
+		}
+		if valid {
+			debugConnf(c, "Discarding byte after short read: %02x\n", b)
+		}
+	}
+}
+
+func readAID(c Transport) (AID, error) {
 	for {
 		b, valid, _, err := telnetRead(c, false)
 		if !valid && err != nil {
@@ -104,17 +204,20 @@ func readAID(c net.Conn) (AID, error) {
 		}
 		if (b == 0x60) || (b >= 0x6b && b <= 0x6e) ||
 			(b >= 0x7a && b <= 0x7d) || (b >= 0x4a && b <= 0x4c) ||
-			(b >= 0xf1 && b <= 0xf9) || (b >= 0xc1 && b <= 0xc9) {
+			(b >= 0xf1 && b <= 0xf9) || (b >= 0xc1 && b <= 0xc9) ||
+			(b == 0x7e || b == 0x7f) {
 			// We found a valid AID
-			debugf("Got AID byte: %x\n", b)
+			debugConnf(c, "Got AID byte: %x\n", b)
 			return AID(b), nil
 		}
 		// Consume non-AID bytes continuing loop
-		debugf("Got non-AID byte: %x\n", b)
+		debugConnf(c, "Got non-AID byte: %x\n", b)
 	}
 }
 
-func readPosition(c net.Conn) (row, col, addr int, err error) {
+func readPosition(c Transport, size screenSize) (row, col, addr int,
+	err error) {
+
 	raw := make([]byte, 2)
 
 	// Read two bytes
@@ -128,89 +231,120 @@ func readPosition(c net.Conn) (row, col, addr int, err error) {
 
 	// Decode the raw position
 	addr = decodeBufAddr([2]byte{raw[0], raw[1]})
-	row = addr % 80
-	col = (addr - row) / 80
+	row = addr / size.cols
+	col = addr % size.cols
 
-	debugf("Got position bytes %02x %02x, decoded to %d\n", raw[0], raw[1],
-		addr)
+	debugConnf(c, "Got position bytes %02x %02x, decoded to %d\n", raw[0],
+		raw[1], addr)
 
 	return row, col, addr, nil
 }
 
-func readFields(c net.Conn, fm fieldmap) (map[string]string, error) {
+// readFields reads the fields of a response up to the telnet EOR, returning
+// the values of the fields in fm by name, the values of all fields by
+// address, and the name of the first field in fm the client sent.
+func readFields(c Transport, fm fieldmap, opts ScreenOpts) (
+	values map[string]string, raw map[int]string, first string, err error) {
+
 	var infield bool
 	var fieldpos int
 	var fieldval bytes.Buffer
-	var values = make(map[string]string)
+	values = make(map[string]string)
+	raw = make(map[int]string)
+	field := func() {
+		debugConnf(c, "Field %d: %s\n", fieldpos, e2a(fieldval.Bytes()))
+		if handleField(fieldpos, fieldval.Bytes(), fm, values, raw,
+			opts) && first == "" {
+			first = fm[fieldpos]
+		}
+	}
 
 	// consume bytes until we get 0xffef
 	for {
 		// Read a byte
 		b, _, eor, err := telnetRead(c, true)
 		if err != nil {
-			return nil, err
+			return nil, nil, "", err
 		}
 
 		// Check for end of data stream (0xffef)
 		if eor {
 			// Finish the current field
 			if infield {
-				debugf("Field %d: %s\n", fieldpos, e2a(fieldval.Bytes()))
-				handleField(fieldpos, fieldval.Bytes(), fm, values)
+				field()
 			}
 
-			return values, nil
+			return values, raw, first, nil
 		}
 
 		// No? Check for start-of-field
 		if b == 0x11 {
 			// Finish the previous field, if necessary
 			if infield {
-				debugf("Field %d: %s\n", fieldpos, e2a(fieldval.Bytes()))
-				handleField(fieldpos, fieldval.Bytes(), fm, values)
+				field()
 			}
 			// Start a new field
 			infield = true
 			fieldval = bytes.Buffer{}
 			fieldpos = 0
 
-			if _, _, fieldpos, err = readPosition(c); err != nil {
-				return nil, err
+			_, _, fieldpos, err = readPosition(c, opts.size())
+			if err != nil {
+				return nil, nil, "", err
 			}
 			continue
 		}
 
 		// Consume all other bytes as field contents if we're in a field
 		if !infield {
-			debugf("Got unexpected byte while processing fields: %02x\n", b)
+			debugConnf(c,
+				"Got unexpected byte while processing fields: %02x\n", b)
 			continue
 		}
 		fieldval.WriteByte(b)
 	}
 }
 
-func handleField(addr int, value []byte, fm fieldmap, values map[string]string) bool {
-	name, ok := fm[addr]
+func handleField(addr int, value []byte, fm fieldmap, values map[string]string,
+	raw map[int]string, opts ScreenOpts) bool {
+
+	// Clients may pad the field value with nulls to the width of the field;
+	// those are never part of the value the user entered.
+	value = bytes.TrimRight(value, "\x00")
+	if opts.RemoveNulls {
+		value = bytes.Replace(value, []byte{0x00}, nil, -1)
+	}
+	raw[addr] = string(e2a(value))
 
 	// Field is not present in the fieldmap
+	name, ok := fm[addr]
 	if !ok {
 		return false
 	}
 
 	// Otherwise, populate the value
-	values[name] = string(e2a(value))
+	values[name] = raw[addr]
 	return true
 }
 
 // decodeBufAddr decodes a raw 2-byte encoded buffer address and returns the
-// integer value of the address (i.e. 0-1919)
+// integer value of the address (i.e. 0-1919 on a 24x80 screen). Both 12-bit
+// and 14-bit addresses are handled: clients may use either in the same
+// session, so the encoding is determined for each address from its top two
+// bits, which are 00 for 14-bit addresses.
 func decodeBufAddr(raw [2]byte) int {
-	if decodes[raw[0]] > 254 {
+	if raw[0]&0xc0 == 0 {
+		// 14-bit address: the low 6 bits of the first byte and all 8 bits
+		// of the second byte.
+		return int(raw[0])<<8 | int(raw[1])
+	}
+
+	if decodes[raw[0]] < 0 {
 		fmt.Fprintf(os.Stderr,
 			"UNEXPECTED VALUE: decodeBufAddr got raw value of %02x %02x\n",
 			raw[0], raw[1])
 	}
-	if decodes[raw[1]] > 254 {
+	if decodes[raw[1]] < 0 {
 		fmt.Fprintf(os.Stderr,
 			"UNEXPECTED VALUE: decodeBufAddr got raw value of %02x %02x\n",
 			raw[0], raw[1])
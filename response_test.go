@@ -0,0 +1,214 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadFieldsNulls(t *testing.T) {
+	// Two fields as sent by an emulator that pads modified fields with
+	// nulls: "ABC" followed by nulls at address 5, and "A" null "B" at
+	// address 85.
+	inbound := []byte{
+		0x11, 0x40, 0xc5, 0xc1, 0xc2, 0xc3, 0x00, 0x00, 0x00,
+		0x11, 0xc1, 0xd5, 0x00, 0xc1, 0x00, 0xc2, 0x00,
+		0xff, 0xef,
+	}
+	fm := fieldmap{5: "name", 85: "other"}
+
+	values, _, _, err := readFields(&recordingTransport{in: bytes.NewReader(inbound)}, fm,
+		ScreenOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values["name"] != "ABC" {
+		t.Errorf("expected trailing nulls to be removed, got %q",
+			values["name"])
+	}
+	if values["other"] != "\x00A\x00B" {
+		t.Errorf("expected leading and embedded nulls to be kept, got %q",
+			values["other"])
+	}
+
+	values, _, _, err = readFields(&recordingTransport{in: bytes.NewReader(inbound)}, fm,
+		ScreenOpts{RemoveNulls: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values["other"] != "AB" {
+		t.Errorf("expected all nulls to be removed, got %q", values["other"])
+	}
+}
+
+func TestPFNumber(t *testing.T) {
+	tests := map[AID]int{AIDPF1: 1, AIDPF9: 9, AIDPF10: 10, AIDPF12: 12,
+		AIDPF13: 13, AIDPF21: 21, AIDPF22: 22, AIDPF24: 24, AIDEnter: 0,
+		AIDPA1: 0, AIDClear: 0}
+	for aid, expected := range tests {
+		if n := aid.PFNumber(); n != expected {
+			t.Errorf("%s: expected %d, got %d", AIDtoString(aid), expected, n)
+		}
+	}
+}
+
+func TestResponseFieldAddresses(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 4, Name: "name", Write: true},
+		{Row: 1, Col: 4, Name: "other", Write: true},
+		{Row: 2, Col: 0},
+	}
+	// Enter with the cursor at address 5 and no modified fields
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc5, 0xff, 0xef})}
+
+	resp, err := ShowScreen(screen, nil, 0, 5, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.FieldAddresses) != 2 || resp.FieldAddresses[85] != "other" {
+		t.Errorf("unexpected field addresses %v", resp.FieldAddresses)
+	}
+	if addr, ok := resp.FieldAddress("name"); !ok || addr != 5 {
+		t.Errorf("expected name at address 5, got %d (ok=%v)", addr, ok)
+	}
+	if _, ok := resp.FieldAddress("missing"); ok {
+		t.Error("expected no address for a missing field")
+	}
+}
+
+func TestWaitForKey(t *testing.T) {
+	// PF1 with the cursor at address 1 and a modified field
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0xf1, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xff, 0xef})}
+
+	resp, err := WaitForKey(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDPF1 || resp.Row != 0 || resp.Col != 1 ||
+		len(resp.Values) != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if conn.written.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %x",
+			conn.written.Bytes())
+	}
+}
+
+func TestRoundTripField(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "version", Hidden: true, RoundTrip: true,
+			Content: "default"},
+		{Row: 1, Col: 0, Name: "name", Write: true},
+		{Row: 1, Col: 10},
+	}
+	// Enter, with the client (incorrectly) sending a value for version
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0x40, 0x11, 0x40, 0xc1, 0xc1, 0xff, 0xef})}
+
+	resp, err := ShowScreen(screen, map[string]string{"version": " v42"},
+		0, 0, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Values["version"] != " v42" {
+		t.Errorf("expected version to round-trip, got %q",
+			resp.Values["version"])
+	}
+	if screen[0].Content != "default" {
+		t.Error("the caller's screen was modified")
+	}
+}
+
+func TestRawFields(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "name", Write: true},
+		{Row: 0, Col: 10},
+	}
+	// Enter with "AB" in the named field and " C" in an unnamed field at
+	// address 81
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1,
+		0x11, 0x40, 0xc1, 0xc1, 0xc2,
+		0x11, 0xc1, 0xd1, 0x40, 0xc3,
+		0xff, 0xef})}
+
+	resp, err := ShowScreen(screen, nil, 0, 1, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.RawFields) != 2 || resp.RawFields[1] != "AB" ||
+		resp.RawFields[81] != " C" {
+		t.Errorf("unexpected raw fields %q", resp.RawFields)
+	}
+	if len(resp.Values) != 1 || resp.Values["name"] != "AB" {
+		t.Errorf("unexpected values %q", resp.Values)
+	}
+}
+
+func TestConsumeShortReads(t *testing.T) {
+	// PA1 with unexpected data before the EOR, then Enter
+	inbound := []byte{0x6c, 0xc1, 0xc2, 0xff, 0xef,
+		0x7d, 0x40, 0x40, 0xff, 0xef}
+	opts := ScreenOpts{ConsumeShortReads: true}
+	conn := &recordingTransport{in: bytes.NewReader(inbound)}
+
+	resp, err := readResponse(conn, fieldmap{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDPA1 {
+		t.Errorf("expected PA1, got %s", AIDtoString(resp.AID))
+	}
+
+	resp, err = readResponse(conn, fieldmap{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter {
+		t.Errorf("expected Enter, got %s", AIDtoString(resp.AID))
+	}
+}
+
+func TestTriggerResponse(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "zip", Write: true, Trigger: true},
+		{Row: 0, Col: 10},
+		{Row: 1, Col: 0, Name: "city", Write: true, Trigger: true},
+		{Row: 1, Col: 10},
+	}
+
+	// The trigger field has the validation attribute with bit 7 set
+	expected := []byte{0x29, 0x02, 0xc0, fieldAttribute(screen[0]),
+		0xc1, 0x01}
+	if got := buildField(screen[0]); !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+
+	// A trigger from city, with a second field the client shouldn't have
+	// sent: the first one in the datastream is the trigger field.
+	in := []byte{0x7f, 0xc1, 0xd5}
+	in = append(in, sba(1, 1, defaultSize)...)
+	in = append(in, a2e([]byte("PARIS"))...)
+	in = append(in, sba(0, 1, defaultSize)...)
+	in = append(in, a2e([]byte("75001"))...)
+	in = append(in, iac, eor)
+	for i := 0; i < 10; i++ {
+		conn := &recordingTransport{in: bytes.NewReader(in)}
+		resp, err := ShowScreen(screen, nil, 0, 0, conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.AID != AIDTrigger || resp.TriggerField != "city" {
+			t.Fatalf("expected a trigger from city, got %s from %q",
+				AIDtoString(resp.AID), resp.TriggerField)
+		}
+		if resp.Values["city"] != "PARIS" {
+			t.Errorf("expected PARIS, got %q", resp.Values["city"])
+		}
+	}
+}
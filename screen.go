@@ -6,20 +6,25 @@ package go3270
 
 import (
 	"bytes"
-	"net"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Field is a field on the 3270 screen.
 type Field struct {
 	// Row is the row, 0-based, that the field attribute character should
-	// begin at. This library currently only supports 24 rows, so Row must
-	// be 0-23.
+	// begin at. On the default 24x80 screen, Row must be 0-23; see
+	// ScreenOpts.Rows for other screen sizes.
 	Row int
 
 	// Col is the column, 0-based, that the field attribute character should
-	// begin at. This library currently only supposed 80 columns, so Column
-	// must be 0-79.
+	// begin at. On the default 24x80 screen, Col must be 0-79; see
+	// ScreenOpts.Cols for other screen sizes.
 	Col int
 
 	// Text is the content of the field to display.
@@ -52,18 +57,130 @@ type Field struct {
 	// is the default (i.e. no) highlighting.
 	Highlighting Highlight
 
+	// MandatoryFill indicates that, if the user enters anything into the
+	// field, the field must be completely filled before the client will
+	// allow the data to be sent. This is sent with the extended field
+	// validation attribute; enforcement is up to the client/controller and
+	// many clients ignore it, so Rules remain the authoritative validation.
+	MandatoryFill bool
+
+	// MandatoryEntry indicates that the user must enter data into the field
+	// before the client will allow the data to be sent. As with
+	// MandatoryFill, enforcement is client-dependent and you must still
+	// validate the input on the server side.
+	MandatoryEntry bool
+
+	// Trigger asks the client to send the field's value to the server as
+	// soon as the user moves the cursor out of the field after modifying it,
+	// without waiting for an AID key. The response will have the AIDTrigger
+	// AID and Response.TriggerField set to the field's name. Include
+	// AIDTrigger in the accepted keys when using HandleScreen(). Trigger is
+	// sent with the extended field validation attribute, and very few
+	// clients support it, so applications must work correctly without it.
+	Trigger bool
+
+	// Outline draws lines along the sides of the field, such as a box
+	// around an input area. It is sent with the extended field outlining
+	// attribute, which not all clients support.
+	Outline Outline
+
+	// Fallback is an alternate definition of this field to send instead
+	// when ScreenOpts.NoExtended is set, e.g. using Intense in place of a
+	// color. The fallback should normally have the same Row, Col, and Name
+	// as this field. Fields without a Fallback simply have their extended
+	// attributes removed.
+	Fallback *Field
+
+	// PenDesignator makes the field detectable with a light pen (or the
+	// cursor select key on terminals without one) and selects how it
+	// behaves when selected. The designator character is displayed before
+	// the field's content. Detectable fields are normally protected fields,
+	// and should be named so selections can be reported in
+	// Response.PenSelections. Hidden fields cannot be detected.
+	PenDesignator PenDesignator
+
 	// Name is the name of this field, which is used to get the user-entered
 	// data. All writeable fields on a screen must have a unique name.
 	Name string
 
+	// MaxWidth, if greater than 0, is the maximum number of screen positions
+	// the field's content may occupy. Longer content (from Content or the
+	// values map) is truncated when the screen is sent, so that unexpectedly
+	// long data doesn't overflow into the fields that follow. Content is
+	// never truncated in the middle of a character.
+	MaxWidth int
+
+	// MaxLength, if greater than 0, is the maximum number of characters the
+	// user may enter in a writable field. Clients don't enforce it: when
+	// the screen is displayed by HandleScreen(), a longer value fails
+	// validation with a "too long" message in the error field. Use MaxLen()
+	// to check lengths in other Validators or outside of HandleScreen().
+	MaxLength int
+
+	// PadToWidth causes the field's content to be padded with spaces to the
+	// full width of the field (up to the next field on the screen) when it
+	// is sent. This overwrites any longer content previously displayed in
+	// the field when updating a screen without clearing it. PadToWidth
+	// doesn't apply to RawContent.
+	PadToWidth bool
+
+	// Justify selects the alignment of a display-only field's content
+	// within the field's width (up to the next field on the screen, or
+	// MaxWidth if that is smaller). The default, JustifyLeft, sends the
+	// content as-is. JustifyRight pads the content with spaces on the left,
+	// so numbers in a column of fields line up. Justify is ignored for
+	// writable fields and doesn't apply to RawContent.
+	Justify Justify
+
+	// RawContent, if not nil, is sent as the field's content instead of
+	// Content or an override from the values map. It is written to the
+	// datastream as-is, without translation to EBCDIC, so it may contain
+	// EBCDIC control characters (see EBCDICNL and friends) and orders that
+	// can't be expressed in Content. RawContent is never padded, so
+	// PadToWidth and Justify have no effect on it; Validate() reports
+	// fields that combine them.
+	RawContent []byte
+
 	// KeepSpaces will prevent the strings.TrimSpace() function from being
 	// called on the field value. Generally you want leading and trailing
 	// spaces trimmed from fields in 3270 before processing, but if you are
 	// building a whitespace-sensitive application, you can ask for the
 	// original, un-trimmed value for a field by setting this to true.
 	KeepSpaces bool
+
+	// Transient marks a named, display-only field as the place for one-shot
+	// messages, such as errors or confirmations, set with
+	// ScreenSession.SetTransient(). A message is displayed in the field by
+	// the session's next ShowScreen() that includes the field, in place of
+	// any value from the values map, and is then forgotten, so it is not
+	// displayed again on the screens that follow. The values map is never
+	// modified. Outside of a ScreenSession, Transient has no effect.
+	Transient bool
+
+	// RoundTrip returns the field's value, from Content or the values map,
+	// in Response.Values exactly as it was sent, without depending on the
+	// client. Protected fields are not normally returned by the client, and
+	// a writable field could be altered by the user. The recommended
+	// pattern for optimistic concurrency is a hidden, protected RoundTrip
+	// field holding the record's version: compare the version in the
+	// response with the record's current version before applying the
+	// user's changes. The value is held by the server for the duration of
+	// the ShowScreen() call and is never read back from the client.
+	RoundTrip bool
 }
 
+// EBCDIC control characters that may be included in Field.RawContent. These
+// control the format of printed output on printers such as the 3287 when
+// the printer's WCC print format is unformatted; on display terminals they
+// do not move the cursor or start a new line and are displayed as blanks
+// (NL, FF, CR) or as a special symbol (EM, by some clients).
+const (
+	EBCDICFF byte = 0x0c // Form Feed: advance to the top of the next page
+	EBCDICCR byte = 0x0d // Carriage Return: return to the start of the line
+	EBCDICNL byte = 0x15 // New Line: advance to the start of the next line
+	EBCDICEM byte = 0x19 // End of Message: stop printing
+)
+
 // Color is a 3270 extended field attribute color value
 type Color byte
 
@@ -79,6 +196,19 @@ const (
 	White        Color = 0xf7
 )
 
+// FourColorMap is a ScreenOpts.ColorMap for clients that can only display
+// the four base colors: blue, red, green, and white. Each of the other
+// colors is shown as the nearest base color:
+//
+//   - Pink is shown as red.
+//   - Turquoise is shown as blue.
+//   - Yellow is shown as white.
+var FourColorMap = map[Color]Color{
+	Pink:      Red,
+	Turquoise: Blue,
+	Yellow:    White,
+}
+
 // Highlight is a 3270 extended field attribute highlighting method
 type Highlight byte
 
@@ -90,11 +220,355 @@ const (
 	Underscore       Highlight = 0xf4
 )
 
+// Outline is a 3270 extended field attribute for field outlining: lines
+// drawn along the sides of the field. Combine the constants with | to draw
+// more than one line.
+type Outline byte
+
+// The field outlining lines
+const (
+	NoOutline    Outline = 0
+	OutlineUnder Outline = 0x01
+	OutlineRight Outline = 0x02
+	OutlineOver  Outline = 0x04
+	OutlineLeft  Outline = 0x08
+
+	// OutlineBox draws all four lines, boxing the field.
+	OutlineBox = OutlineUnder | OutlineRight | OutlineOver | OutlineLeft
+)
+
+// PenDesignator is the light pen designator character of a detectable field,
+// which determines what happens when the user selects the field.
+type PenDesignator byte
+
+// The light pen designators
+const (
+	// NoPen is a field that is not detectable.
+	NoPen PenDesignator = 0
+
+	// PenSelect ("?") is a selection field. Selecting it changes the
+	// designator to ">" and marks the field as selected; selecting it again
+	// reverses this. Selections are sent when the user presses an AID key.
+	PenSelect PenDesignator = '?'
+
+	// PenSelected (">") is a selection field that starts out selected.
+	PenSelected PenDesignator = '>'
+
+	// PenAttention (a blank) is an attention field. Selecting it sends the
+	// AIDSelectorPen AID immediately, with selected fields reported in
+	// Response.PenSelections and no field values.
+	PenAttention PenDesignator = ' '
+
+	// PenAttentionEnter ("&") is an attention field. Selecting it marks the
+	// field as selected and then acts as if the user pressed Enter.
+	PenAttentionEnter PenDesignator = '&'
+)
+
+// Justify is the alignment of a display-only field's content.
+type Justify int
+
+// The field content alignments
+const (
+	JustifyLeft Justify = iota
+	JustifyRight
+)
+
 // Screen is an array of Fields which compose a complete 3270 screen.
 // No checking is performed for lack of overlapping fields, unique field
 // names,
+//
+// The order of the fields in a Screen does not matter to the client: the
+// tab key always moves the cursor between writable fields in the order of
+// their position on the screen, left-to-right and top-to-bottom. Use
+// Sorted() to get a copy of a screen in that order.
 type Screen []Field
 
+// Sorted returns a copy of the screen with the fields in positional order,
+// which is the order the client's tab key visits them. Fields at the same
+// position keep their relative order.
+func (s Screen) Sorted() Screen {
+	result := make(Screen, len(s))
+	copy(result, s)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Row != result[j].Row {
+			return result[i].Row < result[j].Row
+		}
+		return result[i].Col < result[j].Col
+	})
+	return result
+}
+
+// ProtectAll returns a copy of the screen with every writable field made
+// protected, so the user can no longer edit it. This is useful for
+// re-displaying a completed input screen as a read-only confirmation.
+func (s Screen) ProtectAll() Screen {
+	result := make(Screen, len(s))
+	copy(result, s)
+	for i := range result {
+		result[i].Write = false
+	}
+	return result
+}
+
+// WritableFields returns the names of the screen's writable fields, in the
+// order they appear in the screen. Each writable field is listed, so a name
+// mistakenly given to more than one writable field (see Field.Name) appears
+// more than once.
+func (s Screen) WritableFields() []string {
+	var names []string
+	for _, fld := range s {
+		if fld.Write && fld.Name != "" {
+			names = append(names, fld.Name)
+		}
+	}
+	return names
+}
+
+// NamedFields returns the names of all of the screen's named fields, both
+// writable and display-only, in the order they first appear in the screen.
+func (s Screen) NamedFields() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, fld := range s {
+		if fld.Name != "" && !seen[fld.Name] {
+			names = append(names, fld.Name)
+			seen[fld.Name] = true
+		}
+	}
+	return names
+}
+
+// Partial returns a screen containing only the fields of s whose names are
+// keys in values, with each field's Content set to its value, for updating
+// those fields in place on a screen already displayed to the user. Send the
+// result with ScreenSession.Update() or ShowScreenOpts() with WriteOnly:
+// all of the fields go in a single Write datastream, so the user sees them
+// change together. Each value is padded with spaces to the width its field
+// has in s (up to the next field), so a shorter value completely replaces a
+// longer one. Partial is for the default 24x80 screen; use PartialOpts() for
+// other screen sizes.
+func (s Screen) Partial(values map[string]string) Screen {
+	return s.PartialOpts(values, ScreenOpts{})
+}
+
+// PartialOpts is Partial() for the screen size set by opts.Rows and
+// opts.Cols, which should be the options the screen was displayed with.
+// Fields outside of the screen are not included.
+func (s Screen) PartialOpts(values map[string]string, opts ScreenOpts) Screen {
+	size := opts.size()
+	var result Screen
+	for i, fld := range s {
+		value, ok := values[fld.Name]
+		if fld.Name == "" || !ok || !size.valid(fld) {
+			continue
+		}
+		if width := fieldWidth(s, i, size); len(value) < width {
+			value += strings.Repeat(" ", width-len(value))
+		}
+		fld.Content = value
+		fld.PadToWidth = false
+		result = append(result, fld)
+	}
+	return result
+}
+
+// Validate checks the screen for common layout mistakes and returns an error
+// describing each one found, or nil if there are none. Validate reports:
+//
+//   - Writable fields that are not followed by another field before the end
+//     of the line their input area starts on. Without a following "stop"
+//     field, the writable field's attributes (and the user's ability to
+//     type) extend until the next field anywhere on the screen. Use
+//     Normalize() to add stop fields automatically.
+//   - Fields whose Content (or RawContent) is too long to fit on the row
+//     after the field's attribute, and so wraps onto the next row, where it
+//     may overwrite other fields. Set MaxWidth on fields whose content may
+//     be long to have it truncated when the screen is sent.
+//   - Fields with RawContent that also set PadToWidth or Justify, which
+//     have no effect on raw content, since it is sent as-is.
+//
+// Only the screen definition is checked; override values supplied when the
+// screen is shown are not known to Validate. Validate checks the layout for
+// the default 24x80 screen; use ValidateOpts() for other screen sizes.
+func (s Screen) Validate() []error {
+	return s.ValidateOpts(ScreenOpts{})
+}
+
+// ValidateOpts is Validate() for the screen size set by opts.Rows and
+// opts.Cols.
+func (s Screen) ValidateOpts(opts ScreenOpts) []error {
+	size := opts.size()
+	var errs []error
+	for i, fld := range s {
+		if _, missing := missingStop(s, i, size); missing {
+			errs = append(errs, fmt.Errorf(
+				"go3270: writable field %q at row %d, col %d has no stop "+
+					"field before the end of the line", fld.Name, fld.Row,
+				fld.Col))
+		}
+		if size.valid(fld) && fld.Col+1+contentWidth(fld) > size.cols {
+			errs = append(errs, fmt.Errorf(
+				"go3270: content of field %q at row %d, col %d wraps past "+
+					"the end of the row", fld.Name, fld.Row, fld.Col))
+		}
+		if fld.RawContent != nil &&
+			(fld.PadToWidth || fld.Justify != JustifyLeft) {
+			errs = append(errs, fmt.Errorf(
+				"go3270: field %q at row %d, col %d has RawContent, which "+
+					"is not padded or justified", fld.Name, fld.Row,
+				fld.Col))
+		}
+	}
+	return errs
+}
+
+// Resolve returns the screen as ShowScreenOpts() sends it with opts: with
+// NoExtended set, fields with a Fallback are replaced by their fallback and
+// the other fields have their extended attributes removed; otherwise, the
+// field colors in ColorMap are replaced. This is useful for checking what
+// limited clients will display, for example with the render package.
+func (s Screen) Resolve(opts ScreenOpts) Screen {
+	return resolveFallbacks(s, opts)
+}
+
+// rawWidth returns the number of screen positions occupied by raw field
+// content, allowing for the Graphic Escape and Set Attribute orders.
+func rawWidth(raw []byte) int {
+	width := 0
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case 0x08: // GE: the next byte is one character
+			i++
+			width++
+		case 0x28: // SA: an attribute type and value, no characters
+			i += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// DisplayRunes returns the characters the field displays after its field
+// attribute, one for each screen position, when it is sent with the
+// override values as ShowScreen() does: content is truncated to MaxWidth,
+// and detectable fields start with their PenDesignator. It is intended for
+// drawing screens without a client, as the render package does.
+//
+// RawContent is decoded: EBCDIC characters are translated, line drawing
+// characters sent with the Graphic Escape order are returned as their
+// Unicode equivalents (or '?' for other graphic characters), Set Attribute
+// orders occupy no positions, and EBCDIC control characters are returned
+// as 0, which clients display as a blank.
+func (f Field) DisplayRunes(values map[string]string) []rune {
+	if f.RawContent != nil {
+		return rawRunes(f.RawContent)
+	}
+
+	// Every byte of the content is sent as one EBCDIC character.
+	content := fieldContent(f, values)
+	result := make([]rune, len(content))
+	for i := 0; i < len(content); i++ {
+		result[i] = rune(content[i])
+	}
+	return result
+}
+
+// rawRunes returns the characters displayed for raw field content, allowing
+// for the Graphic Escape and Set Attribute orders as rawWidth() does.
+func rawRunes(raw []byte) []rune {
+	result := make([]rune, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == 0x08: // GE: the next byte is one character
+			i++
+			r, ok := rune('?'), false
+			if i < len(raw) {
+				r, ok = graphicRunes[raw[i]]
+			}
+			if !ok {
+				r = '?'
+			}
+			result = append(result, r)
+		case raw[i] == 0x28: // SA: an attribute type and value
+			i += 2
+		case raw[i] < 0x40: // control characters display as blanks
+			result = append(result, 0)
+		default:
+			result = append(result, rune(ascii[raw[i]]))
+		}
+	}
+	return result
+}
+
+// contentWidth returns the number of screen positions the field's own
+// content occupies when sent, taking MaxWidth into account.
+func contentWidth(fld Field) int {
+	if fld.RawContent != nil {
+		return rawWidth(fld.RawContent)
+	}
+	if fld.MaxWidth > 0 {
+		return len(truncateContent(fld.Content, fld.MaxWidth))
+	}
+	return len(fld.Content)
+}
+
+// Normalize returns a copy of the screen with a stop field added for each
+// writable field that Validate() would report as missing one. The stop field
+// is placed in the last column of the line, so the writable field extends
+// to the end of the line but no further. Normalize is for the default 24x80
+// screen; use NormalizeOpts() for other screen sizes.
+func (s Screen) Normalize() Screen {
+	return s.NormalizeOpts(ScreenOpts{})
+}
+
+// NormalizeOpts is Normalize() for the screen size set by opts.Rows and
+// opts.Cols.
+func (s Screen) NormalizeOpts(opts ScreenOpts) Screen {
+	size := opts.size()
+	result := make(Screen, len(s))
+	copy(result, s)
+	for i := range s {
+		if addr, missing := missingStop(s, i, size); missing {
+			result = append(result,
+				Field{Row: addr / size.cols, Col: addr % size.cols})
+		}
+	}
+	return result
+}
+
+// missingStop returns true if the field at index i of the screen is a
+// writable field with no field attribute following it on the line its input
+// area starts on. It also returns the buffer address a stop field should be
+// placed at.
+func missingStop(s Screen, i int, size screenSize) (int, bool) {
+	fld := s[i]
+	if !fld.Write || !size.valid(fld) {
+		return 0, false
+	}
+
+	start := size.addr(fld.Row, fld.Col) + 1
+	// address of the next line's first column
+	lineEnd := (start/size.cols + 1) * size.cols
+	if start+fieldWidth(s, i, size) <= lineEnd {
+		return 0, false
+	}
+
+	stop := lineEnd - 1
+	if stop == start {
+		// The input area starts in the last column; stop on the next line.
+		stop = lineEnd
+	}
+	return stop % size.buffer(), true
+}
+
+// Write Control Characters used with the Write command for updates.
+const (
+	wccUpdate = 0xc2 // WCC = Reset, Unlock Keyboard
+	wccLocked = 0x40 // WCC = Reset; keyboard remains locked
+	wccAlarm  = 0xc4 // WCC = Reset, Sound alarm; keyboard left as it is
+)
+
 // fieldmap is a map of field buffer addresses and the corresponding field
 // name.
 type fieldmap map[int]string
@@ -108,33 +582,671 @@ type fieldmap map[int]string
 // row 0-23 and col 0-79. Errors from conn.Write() are returned if
 // encountered.
 func ShowScreen(screen Screen, values map[string]string, crow, ccol int,
-	conn net.Conn) (Response, error) {
+	conn Transport) (Response, error) {
 
-	var b bytes.Buffer
-	var fm = make(fieldmap) // field buffer positions -> name
+	return ShowScreenOpts(screen, values, conn,
+		ScreenOpts{CursorRow: crow, CursorCol: ccol})
+}
+
+// ScreenOpts are the options for displaying a screen with ShowScreenOpts().
+type ScreenOpts struct {
+	// CursorRow and CursorCol are the 0-based position to place the cursor
+	// at after writing the screen, unless CursorField is set.
+	CursorRow int
+	CursorCol int
+
+	// CursorField is the name of a field to place the cursor in. When set
+	// to the name of a field on the screen, CursorRow and CursorCol are
+	// ignored and the cursor is placed CursorFieldOffset positions into the
+	// field (0 is the field's first position, just after its attribute).
+	// The offset is clamped to the width of the field, which extends until
+	// the next field on the screen.
+	CursorField       string
+	CursorFieldOffset int
+
+	// CursorAfterField is the name of a field to place the cursor in, just
+	// after the end of the field's content (from Content or the values map,
+	// ignoring trailing spaces), so the user can continue typing where the
+	// existing text ends. The position is clamped to the width of the
+	// field. CursorAfterField is ignored if CursorField is set.
+	CursorAfterField string
+
+	// NoExtended indicates the client does not support extended field
+	// attributes (color, highlighting, and validation). Fields with a
+	// Fallback are replaced by their fallback field, and all other fields
+	// are sent without their extended attributes, so one screen definition
+	// may be used for both capable and limited clients.
+	NoExtended bool
+
+	// ColorMap, if not nil, replaces field colors the client can't display
+	// with ones it can: each field whose Color is a key in the map is sent
+	// with the corresponding value instead. This keeps screens legible on
+	// clients with limited color support without dropping the extended
+	// attributes entirely as NoExtended does. Use FourColorMap for clients
+	// that only support the four base colors. ColorMap is ignored if
+	// NoExtended is set.
+	ColorMap map[Color]Color
+
+	// RemoveNulls causes all null characters to be removed from the field
+	// values returned by the client. Trailing nulls, which some clients use
+	// to pad a field to its full width, are always removed; this also
+	// removes leading and embedded nulls, which appear when the user moves
+	// the cursor past empty positions in a field before typing.
+	RemoveNulls bool
+
+	// ConsumeShortReads causes the response to the Clear and PA keys, which
+	// carry no cursor position or field data, to be read up to the end of
+	// the client's record, discarding anything else in it. Without it,
+	// reading stops after the AID, and any unexpected data that follows
+	// is skipped when the next response is read. Some clients send a short
+	// read immediately followed by another response; with this option, the
+	// next read starts cleanly at the second response's AID.
+	ConsumeShortReads bool
+
+	// EraseMode selects whether the screen is cleared before the fields are
+	// written. The default, EraseWrite, clears the screen.
+	EraseMode EraseMode
+
+	// WCCOverride, if not nil, replaces the Write Control Character that
+	// go3270 would otherwise send with the screen (normally 0xc3: reset,
+	// restore keyboard, reset modified data tags). This is for advanced
+	// uses only; you are responsible for setting the WCC bits correctly,
+	// including the graphic-character encoding of the top two bits. For
+	// example, omitting the keyboard restore bit leaves the keyboard locked
+	// and the user unable to respond. Normal callers should leave this nil.
+	WCCOverride *byte
+
+	// SoundAlarm sounds the client's audible alarm when the screen is
+	// written, to draw the user's attention to it (an error condition
+	// that must be noticed, for example). The alarm bit is added to the
+	// WCC, including one from WCCOverride.
+	SoundAlarm bool
+
+	// NoCursorMove omits the Insert Cursor order, so the cursor position is
+	// not set by go3270 and the cursor position options are ignored. When
+	// the screen is erased, the client places the cursor at its default
+	// position (normally the top left corner); with WriteOnly, the cursor
+	// stays wherever it was.
+	NoCursorMove bool
+
+	// ClearFields are the names of fields to clear on the client's screen
+	// before the fields are written. This is needed with WriteOnly, where a
+	// field sent with an empty value leaves its previous content on the
+	// screen, for example to clear a command line after running the
+	// command. Each named field is filled with nulls up to the next field;
+	// nulls display as blanks, and in input fields they leave room for the
+	// user to type in insert mode. Any content for the field from Content
+	// or the values map is written after the field is cleared.
+	ClearFields []string
+
+	// Registry, if not nil, is the SessionRegistry that tracks this
+	// connection while it waits for the user's response, so the wait can be
+	// interrupted by SessionRegistry.Shutdown().
+	Registry *SessionRegistry
+
+	// InputTimeout, if greater than 0, is how long to wait for the user to
+	// respond to the screen. If no response arrives in time,
+	// ShowScreenOpts() returns ErrInputTimeout, and the application may
+	// log the user off or display a warning. This is also available to
+	// HandleScreenOpts() through HandleOpts.ScreenOpts.
+	InputTimeout time.Duration
+
+	// LogPrefix, if not empty, precedes the debug output (see Debug) about
+	// writing this screen and reading the response, so the output for each
+	// connection can be told apart on a server with many users. A
+	// connection or session ID is a good choice.
+	LogPrefix string
+
+	// Rows and Cols are the size of the client's screen, for clients with
+	// a screen size other than the default 24x80, such as model 4 (43x80)
+	// and model 5 (27x132) terminals. They are used only when both are
+	// non-zero, in which case the screen is written with the Erase/Write
+	// Alternate command, which switches the client to its alternate screen
+	// size; they must match the client's alternate size. Field positions
+	// and cursor positions, including those in the Response, are checked
+	// and calculated using this size. Screens larger than 16384 positions
+	// can't be addressed and ShowScreenOpts() returns ErrScreenSize.
+	Rows int
+	Cols int
+}
+
+// ErrInputTimeout is returned by ShowScreenOpts() when the user doesn't
+// respond within ScreenOpts.InputTimeout.
+var ErrInputTimeout = errors.New("go3270: timed out waiting for input")
+
+// ErrScreenSize is returned by ShowScreenOpts() when ScreenOpts.Rows and
+// ScreenOpts.Cols describe a screen too large to address.
+var ErrScreenSize = errors.New("go3270: screen size exceeds 14-bit addressing")
+
+// EraseMode selects the 3270 command used to write a screen.
+type EraseMode int
 
-	b.WriteByte(0xf5) // Erase/Write to terminal
-	b.WriteByte(0xc3) // WCC = Reset, Unlock Keyboard, Reset MDT
+const (
+	// EraseWrite clears the screen, then writes the fields (the 3270
+	// Erase/Write command).
+	EraseWrite EraseMode = iota
+
+	// WriteOnly writes the fields over the current contents of the screen
+	// without clearing it first (the 3270 Write command). Anything on the
+	// screen not overwritten by the new fields remains, but only the new
+	// screen's writable fields are returned in the Response.
+	WriteOnly
+)
+
+// ShowScreenOpts writes the 3270 datastream for the screen to a connection
+// and waits for the client's response, as ShowScreen() does, with the
+// additional display options in opts.
+func ShowScreenOpts(screen Screen, values map[string]string, conn Transport,
+	opts ScreenOpts) (Response, error) {
+
+	return showScreenInternal(context.Background(), screen, values, conn,
+		opts)
+}
+
+// ShowScreenContext is ShowScreenOpts() with cancellation: if ctx is
+// cancelled or its deadline passes while waiting for the client's response,
+// the wait is abandoned and ctx.Err() is returned. This allows long-lived
+// sessions to be drained when a server shuts down. The wait is interrupted
+// with a read deadline on conn, which is cleared again before returning;
+// the screen remains displayed and the connection may still be used.
+func ShowScreenContext(ctx context.Context, screen Screen,
+	values map[string]string, conn Transport, opts ScreenOpts) (Response,
+	error) {
+
+	return showScreenInternal(ctx, screen, values, conn, opts)
+}
+
+// WaitForKey waits for the user to press an AID key on the screen already
+// displayed, without sending anything to the client, and returns the AID
+// and cursor position. Field values are not returned. This is useful for
+// "press any key" prompts drawn with a WriteOnly update or Update(), where
+// nothing needs to be re-sent.
+func WaitForKey(conn Transport) (Response, error) {
+	return readResponse(newReadConn(conn, ""), fieldmap{}, ScreenOpts{})
+}
+
+// showScreenInternal is the implementation of ShowScreenOpts() and
+// ShowScreenContext().
+func showScreenInternal(ctx context.Context, screen Screen,
+	values map[string]string, conn Transport, opts ScreenOpts) (Response,
+	error) {
+
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	screen = resolveRoundTrip(resolveFallbacks(screen, opts), values)
+
+	fm, err := writeScreen(screen, values, opts, conn)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return awaitResponse(ctx, screen, fm, conn, opts)
+}
+
+// awaitResponse waits for and reads the client's response to the screen,
+// which has been sent with the fieldmap fm, registering the wait with
+// opts.Registry if there is one. The wait ends with ctx.Err() if ctx is
+// done, or ErrInputTimeout if opts.InputTimeout passes, whichever is first.
+// Both are enforced with a single read deadline on conn, which is cleared
+// again before returning.
+func awaitResponse(ctx context.Context, screen Screen, fm fieldmap,
+	conn Transport, opts ScreenOpts) (Response, error) {
+
+	// With a registry, deadlines are set through the registration so they
+	// can't overwrite an earlier deadline set by Shutdown(), or vice versa.
+	setDeadline := conn.SetReadDeadline
+	var reg *registration
+	if opts.Registry != nil {
+		var err error
+		if reg, err = opts.Registry.wait(conn); err != nil {
+			return Response{}, err
+		}
+		setDeadline = reg.setDeadline
+	}
+
+	var deadline time.Time
+	if opts.InputTimeout > 0 {
+		deadline = time.Now().Add(opts.InputTimeout)
+	}
+	d, ctxDeadline := ctx.Deadline()
+	if ctxDeadline && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	} else {
+		ctxDeadline = false
+	}
+	if !deadline.IsZero() {
+		setDeadline(deadline)
+	}
+
+	// Cancellation interrupts the read by moving the deadline to now. The
+	// watcher starts only once the deadline above is set, so nothing can
+	// overwrite it.
+	var response Response
+	err := ctx.Err()
+	watching := ctx.Done() != nil
+	if err == nil {
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		if watching {
+			go func() {
+				defer close(stopped)
+				select {
+				case <-ctx.Done():
+					setDeadline(time.Now())
+				case <-stop:
+				}
+			}()
+		}
+		response, err = readScreenResponse(screen, fm, readerFor(conn, opts),
+			opts)
+		if watching {
+			close(stop)
+			<-stopped
+		}
+	}
+
+	// Shutdown() may have set a deadline through the registration too
+	shutdown := reg != nil && opts.Registry.done(reg)
+	if !deadline.IsZero() || watching || reg != nil {
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	switch {
+	case shutdown:
+		return response, ErrShutdown
+	case err != nil && ctx.Err() != nil:
+		return response, ctx.Err()
+	case isTimeout(err) && ctxDeadline:
+		// The read deadline can pass just before ctx notices
+		return response, context.DeadlineExceeded
+	case isTimeout(err):
+		return response, ErrInputTimeout
+	}
+	return response, err
+}
+
+// readerFor returns the readConn to read the response to a screen sent with
+// opts from: conn itself if it is already a readConn, such as one from a
+// ScreenSession, or a new one.
+func readerFor(conn Transport, opts ScreenOpts) *readConn {
+	if rc, ok := conn.(*readConn); ok {
+		return rc
+	}
+	return newReadConn(conn, opts.LogPrefix)
+}
+
+// fieldCursor returns the row and column that is offset positions into the
+// first field on the screen with the given name. The offset is clamped to
+// the field's width. ok is false if there is no such field.
+func fieldCursor(screen Screen, name string, offset int,
+	size screenSize) (row, col int, ok bool) {
+
+	for i, fld := range screen {
+		if fld.Name != name || !size.valid(fld) {
+			continue
+		}
+		width := fieldWidth(screen, i, size)
+		if offset > width-1 {
+			offset = width - 1
+		}
+		if offset < 0 {
+			offset = 0
+		}
+		addr := (size.addr(fld.Row, fld.Col) + 1 + offset) % size.buffer()
+		return addr / size.cols, addr % size.cols, true
+	}
+	return 0, 0, false
+}
+
+// contentEnd returns the length, ignoring trailing spaces, of the content
+// that will be sent for the first field with the given name.
+func contentEnd(screen Screen, name string, values map[string]string,
+	size screenSize) int {
 
-	// Build the commands for each field on the screen
 	for _, fld := range screen {
-		if fld.Row < 0 || fld.Row > 23 || fld.Col < 0 || fld.Col > 79 {
+		if fld.Name != name || !size.valid(fld) {
+			continue
+		}
+		if fld.RawContent != nil {
+			return len(fld.RawContent)
+		}
+		return len(strings.TrimRight(fieldContent(fld, values), " "))
+	}
+	return 0
+}
+
+// cursorField returns the name of the writable field on the screen whose
+// input area contains the buffer address addr, or "" if there is none.
+func cursorField(screen Screen, addr int, size screenSize) string {
+	for i, fld := range screen {
+		if !fld.Write || !size.valid(fld) {
+			continue
+		}
+		offset := (addr - (size.addr(fld.Row, fld.Col) + 1) + size.buffer()) %
+			size.buffer()
+		if offset < fieldWidth(screen, i, size) {
+			return fld.Name
+		}
+	}
+	return ""
+}
+
+// fieldWidth returns the number of character positions in the field at index
+// i of the screen: the positions between the field's attribute and the next
+// field attribute in buffer order, wrapping around the end of the buffer.
+func fieldWidth(screen Screen, i int, size screenSize) int {
+	addr := size.addr(screen[i].Row, screen[i].Col)
+	next := size.buffer() // distance to the next attribute; ourself if none
+	for j, fld := range screen {
+		if j == i || !size.valid(fld) {
+			continue
+		}
+		distance := (size.addr(fld.Row, fld.Col) - addr + size.buffer()) %
+			size.buffer()
+		if distance > 0 && distance < next {
+			next = distance
+		}
+	}
+	return next - 1
+}
+
+// resolveFallbacks returns the screen with each field replaced by the
+// field that should be sent under opts. When opts.NoExtended is false, the
+// screen is returned unchanged, apart from any colors replaced by
+// opts.ColorMap.
+func resolveFallbacks(screen Screen, opts ScreenOpts) Screen {
+	if !opts.NoExtended {
+		return mapColors(screen, opts.ColorMap)
+	}
+
+	result := make(Screen, len(screen))
+	for i, fld := range screen {
+		if fld.Fallback != nil {
+			result[i] = *fld.Fallback
+			continue
+		}
+		fld.Color = DefaultColor
+		fld.Highlighting = DefaultHighlight
+		fld.MandatoryFill = false
+		fld.MandatoryEntry = false
+		fld.Trigger = false
+		fld.Outline = NoOutline
+		result[i] = fld
+	}
+	return result
+}
+
+// mapColors returns the screen with each field color that is a key in
+// colors replaced by its value. The screen is returned unchanged if there
+// is nothing to replace.
+func mapColors(screen Screen, colors map[Color]Color) Screen {
+	var result Screen
+	for i, fld := range screen {
+		color, ok := colors[fld.Color]
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = make(Screen, len(screen))
+			copy(result, screen)
+		}
+		result[i].Color = color
+	}
+	if result == nil {
+		return screen
+	}
+	return result
+}
+
+// resolveRoundTrip returns the screen with the Content of each RoundTrip
+// field set to the value that will be sent for it, so the value can be
+// returned with the response. The screen is returned unchanged if it has no
+// RoundTrip fields.
+func resolveRoundTrip(screen Screen, values map[string]string) Screen {
+	var result Screen
+	for i, fld := range screen {
+		if !fld.RoundTrip || fld.Name == "" {
+			continue
+		}
+		if result == nil {
+			result = make(Screen, len(screen))
+			copy(result, screen)
+		}
+		if val, ok := values[fld.Name]; ok {
+			result[i].Content = val
+		}
+	}
+	if result == nil {
+		return screen
+	}
+	return result
+}
+
+// screenSize is the number of rows and columns on the client's screen.
+type screenSize struct {
+	rows, cols int
+}
+
+// defaultSize is the 24x80 screen used unless ScreenOpts.Rows and
+// ScreenOpts.Cols are set.
+var defaultSize = screenSize{rows: 24, cols: 80}
+
+// maxBufferSize is the largest screen that can be addressed with 14-bit
+// buffer addresses.
+const maxBufferSize = 1 << 14
+
+// size returns the screen size selected by the options.
+func (opts ScreenOpts) size() screenSize {
+	if opts.Rows > 0 && opts.Cols > 0 {
+		return screenSize{rows: opts.Rows, cols: opts.Cols}
+	}
+	return defaultSize
+}
+
+// addr returns the buffer address of row and col.
+func (s screenSize) addr(row, col int) int {
+	return row*s.cols + col
+}
+
+// buffer returns the number of positions in the screen buffer.
+func (s screenSize) buffer() int {
+	return s.rows * s.cols
+}
+
+// valid returns true if the field is within the screen.
+func (s screenSize) valid(fld Field) bool {
+	return fld.Row >= 0 && fld.Row < s.rows && fld.Col >= 0 &&
+		fld.Col < s.cols
+}
+
+// writeScreen writes the Erase/Write datastream for the screen to the
+// connection, returning the fieldmap for the screen's writable fields.
+func writeScreen(screen Screen, values map[string]string, opts ScreenOpts,
+	conn Transport) (fieldmap, error) {
+
+	if opts.size().buffer() > maxBufferSize {
+		return nil, ErrScreenSize
+	}
+
+	datastream, fm := buildDatastream(screen, values, opts)
+
+	// Now write the datastream to the writer, returning any potential error.
+	debugPrefixf(opts.LogPrefix, "sending datastream: %x\n", datastream)
+	debugDatastream(opts.LogPrefix, opts.size(), datastream)
+	if err := writeAll(conn, datastream); err != nil {
+		return nil, err
+	}
+
+	return fm, nil
+}
+
+// writeUpdate writes the fields in screen to the connection with a Write
+// command, which leaves the rest of the screen and the cursor position
+// unchanged. It does not wait for a response. The wcc is normally
+// wccUpdate, and size is the size of the screen on display.
+func writeUpdate(screen Screen, values map[string]string, wcc byte,
+	size screenSize, conn Transport) error {
+
+	var b bytes.Buffer
+	b.WriteByte(0xf1) // Write to terminal
+	b.WriteByte(wcc)
+	buildFields(&b, screen, values, size)
+
+	// Escape any IAC bytes in the datastream, then add Telnet IAC EOR
+	datastream := append(telnetEscape(b.Bytes()), iac, eor)
+
+	debugf("sending update datastream: %x\n", datastream)
+	debugDatastream("", size, datastream)
+	return writeAll(conn, datastream)
+}
+
+// DatastreamSize returns the number of bytes ShowScreen() will send to the
+// client for the screen with the override values and options. This may be
+// used to log or budget the bandwidth used by screens on slow links.
+func DatastreamSize(screen Screen, values map[string]string,
+	opts ScreenOpts) int {
+
+	datastream, _ := buildDatastream(resolveFallbacks(screen, opts), values,
+		opts)
+	return len(datastream)
+}
+
+// buildDatastream returns the complete Erase/Write (or Write) datastream for
+// the screen, including the trailing telnet EOR, and the fieldmap for the
+// screen's writable fields.
+func buildDatastream(screen Screen, values map[string]string,
+	opts ScreenOpts) ([]byte, fieldmap) {
+
+	var b bytes.Buffer
+	size := opts.size()
+
+	if opts.EraseMode == WriteOnly {
+		b.WriteByte(0xf1) // Write to terminal
+	} else if opts.Rows > 0 && opts.Cols > 0 {
+		b.WriteByte(0x7e) // Erase/Write Alternate to terminal
+	} else {
+		b.WriteByte(0xf5) // Erase/Write to terminal
+	}
+	wcc := byte(0xc3) // WCC = Reset, Unlock Keyboard, Reset MDT
+	if opts.WCCOverride != nil {
+		wcc = *opts.WCCOverride
+	}
+	if opts.SoundAlarm {
+		wcc |= 0x04 // Sound Alarm
+	}
+	b.WriteByte(wcc)
+
+	b.Write(clearOrders(screen, opts.ClearFields, size))
+	fm := buildFields(&b, screen, values, size)
+
+	b.Write(cursorOrders(screen, values, opts))
+
+	// Escape any IAC bytes in the datastream, then add Telnet IAC EOR
+	return append(telnetEscape(b.Bytes()), iac, eor), fm
+}
+
+// clearOrders returns the orders to fill the named fields of the screen with
+// nulls, using the Repeat to Address order.
+func clearOrders(screen Screen, names []string, size screenSize) []byte {
+	var result []byte
+	for i, fld := range screen {
+		if fld.Name == "" || !size.valid(fld) {
+			continue
+		}
+		for _, name := range names {
+			if name != fld.Name {
+				continue
+			}
+			width := fieldWidth(screen, i, size)
+			if width == 0 {
+				break
+			}
+			start := (size.addr(fld.Row, fld.Col) + 1) % size.buffer()
+			stop := (start + width) % size.buffer()
+			result = append(result, sba(start/size.cols, start%size.cols,
+				size)...)
+			result = append(result, 0x3c) // RA
+			result = append(result, getpos(stop/size.cols, stop%size.cols,
+				size)...)
+			result = append(result, 0x00)
+			break
+		}
+	}
+	return result
+}
+
+// cursorOrders returns the orders to position the cursor as requested in
+// opts, or nil if opts.NoCursorMove is set.
+func cursorOrders(screen Screen, values map[string]string,
+	opts ScreenOpts) []byte {
+
+	if opts.NoCursorMove {
+		return nil
+	}
+
+	size := opts.size()
+	crow, ccol := opts.CursorRow, opts.CursorCol
+	if opts.CursorField != "" {
+		if r, c, ok := fieldCursor(screen, opts.CursorField,
+			opts.CursorFieldOffset, size); ok {
+			crow, ccol = r, c
+		}
+	} else if opts.CursorAfterField != "" {
+		if r, c, ok := fieldCursor(screen, opts.CursorAfterField,
+			contentEnd(screen, opts.CursorAfterField, values, size),
+			size); ok {
+			crow, ccol = r, c
+		}
+	}
+
+	// Set cursor position. Correct out-of-bounds values to 0.
+	if crow < 0 || crow >= size.rows {
+		crow = 0
+	}
+	if ccol < 0 || ccol >= size.cols {
+		ccol = 0
+	}
+	return ic(crow, ccol, size)
+}
+
+// buildFields writes the orders for each field on the screen to b, and
+// returns the fieldmap for the screen's writable fields. Fields that aren't
+// valid (e.g. outside of the screen) are silently ignored.
+func buildFields(b *bytes.Buffer, screen Screen, values map[string]string,
+	size screenSize) fieldmap {
+
+	var fm = make(fieldmap) // field buffer positions -> name
+
+	for i, fld := range screen {
+		if !size.valid(fld) {
 			// Invalid field position
 			continue
 		}
 
-		b.Write(sba(fld.Row, fld.Col))
+		b.Write(sba(fld.Row, fld.Col, size))
 		b.Write(buildField(fld))
 
-		// Use fld.Content, unless the field is named and appears in the
-		// value map.
-		content := fld.Content
-		if fld.Name != "" {
-			if val, ok := values[fld.Name]; ok {
-				content = val
+		content := fieldContent(fld, values)
+		if fld.Justify == JustifyRight && !fld.Write {
+			width := fieldWidth(screen, i, size)
+			if fld.MaxWidth > 0 && fld.MaxWidth < width {
+				width = fld.MaxWidth
+			}
+			content = strings.TrimRight(content, " ")
+			if len(content) < width {
+				content = strings.Repeat(" ", width-len(content)) + content
 			}
 		}
-		if content != "" {
+		if fld.PadToWidth {
+			if width := fieldWidth(screen, i, size); len(content) < width {
+				content += strings.Repeat(" ", width-len(content))
+			}
+		}
+		if fld.RawContent != nil {
+			b.Write(fld.RawContent)
+		} else if content != "" {
 			b.Write(a2e([]byte(content)))
 		}
 
@@ -142,33 +1254,70 @@ func ShowScreen(screen Screen, values map[string]string, crow, ccol int,
 		// to make the value match the reported position (I'm guessing it's
 		// because we get the position of the field's first input position,
 		// not the position of the field attribute byte).
-		if fld.Write {
-			bufaddr := fld.Row*80 + fld.Col
+		// Detectable fields are also returned when selected.
+		if fld.Write || (fld.PenDesignator != NoPen && fld.Name != "") {
+			bufaddr := size.addr(fld.Row, fld.Col)
 			fm[bufaddr+1] = fld.Name
 		}
 	}
 
-	// Set cursor position. Correct out-of-bounds values to 0.
-	if crow < 0 || crow > 23 {
-		crow = 0
+	return fm
+}
+
+// fieldContent returns the text content to send for the field: fld.Content,
+// unless the field is named and appears in the values map, limited to
+// MaxWidth and preceded by any light pen designator.
+func fieldContent(fld Field, values map[string]string) string {
+	content := fld.Content
+	if fld.Name != "" {
+		if val, ok := values[fld.Name]; ok {
+			content = val
+		}
 	}
-	if ccol < 0 || ccol > 79 {
-		ccol = 0
+	if fld.MaxWidth > 0 {
+		content = truncateContent(content, fld.MaxWidth)
 	}
-	b.Write(ic(crow, ccol))
-
-	b.Write([]byte{0xff, 0xef}) // Telnet IAC EOR
-
-	// Now write the datastream to the writer, returning any potential error.
-	debugf("sending datastream: %x\n", b.Bytes())
-	if _, err := conn.Write(b.Bytes()); err != nil {
-		return Response{}, err
+	if fld.PenDesignator != NoPen {
+		content = string(fld.PenDesignator) + content
 	}
+	return content
+}
+
+// readScreenResponse reads the client's response to the screen, which was
+// sent with the fieldmap fm.
+func readScreenResponse(screen Screen, fm fieldmap, conn Transport,
+	opts ScreenOpts) (Response, error) {
 
-	response, err := readResponse(conn, fm)
+	response, err := readResponse(conn, fm, opts)
 	if err != nil {
 		return response, err
 	}
+	size := opts.size()
+
+	response.FieldAddresses = make(map[int]string, len(fm))
+	for addr, name := range fm {
+		response.FieldAddresses[addr] = name
+	}
+
+	// The clear and PA keys don't send the cursor position
+	if !(response.AID == AIDClear || response.AID == AIDPA1 ||
+		response.AID == AIDPA2 || response.AID == AIDPA3) {
+		response.CursorField = cursorField(screen,
+			size.addr(response.Row, response.Col), size)
+	}
+
+	// Move selected light pen fields from Values to PenSelections
+	for _, fld := range screen {
+		if fld.PenDesignator == NoPen || fld.Write {
+			continue
+		}
+		delete(response.FieldAddresses, size.addr(fld.Row, fld.Col)+1)
+		if _, ok := response.Values[fld.Name]; ok {
+			response.PenSelections = append(response.PenSelections,
+				fld.Name)
+			delete(response.Values, fld.Name)
+		}
+	}
 
 	// Strip leading+trailing spaces from field values
 	for _, fld := range screen {
@@ -180,14 +1329,39 @@ func ShowScreen(screen Screen, values map[string]string, crow, ccol int,
 		}
 	}
 
+	// Return the RoundTrip values as they were sent
+	for _, fld := range screen {
+		if fld.RoundTrip && fld.Name != "" {
+			if response.Values == nil {
+				response.Values = make(map[string]string)
+			}
+			response.Values[fld.Name] = fld.Content
+		}
+	}
+
 	return response, nil
 }
 
+// truncateContent returns the longest prefix of content, ending on a
+// character boundary, that occupies no more than width screen positions when
+// translated to EBCDIC.
+func truncateContent(content string, width int) string {
+	if len(content) <= width {
+		return content
+	}
+	// Every byte of the content is translated to one EBCDIC byte, so back
+	// up from width until we're at the start of a UTF-8 sequence.
+	for width > 0 && !utf8.RuneStart(content[width]) {
+		width--
+	}
+	return content[:width]
+}
+
 // sba is the "set buffer address" 3270 command.
-func sba(row, col int) []byte {
+func sba(row, col int, size screenSize) []byte {
 	result := make([]byte, 1, 3)
 	result[0] = 0x11 // SBA
-	result = append(result, getpos(row, col)...)
+	result = append(result, getpos(row, col, size)...)
 	return result
 }
 
@@ -195,11 +1369,12 @@ func sba(row, col int) []byte {
 // field.
 func buildField(f Field) []byte {
 	var buf bytes.Buffer
-	if f.Color == DefaultColor && f.Highlighting == DefaultHighlight {
+	if f.Color == DefaultColor && f.Highlighting == DefaultHighlight &&
+		!f.MandatoryFill && !f.MandatoryEntry && !f.Trigger &&
+		f.Outline == NoOutline {
 		// this is a traditional field, issue a normal sf command
 		buf.WriteByte(0x1d) // sf - "start field"
-		buf.WriteByte(sfAttribute(f.Write, f.Intense, f.Hidden, f.Autoskip,
-			f.NumericOnly))
+		buf.WriteByte(fieldAttribute(f))
 		return buf.Bytes()
 	}
 
@@ -212,12 +1387,17 @@ func buildField(f Field) []byte {
 	if f.Highlighting != DefaultHighlight {
 		paramCount++
 	}
+	if f.MandatoryFill || f.MandatoryEntry || f.Trigger {
+		paramCount++
+	}
+	if f.Outline != NoOutline {
+		paramCount++
+	}
 	buf.WriteByte(paramCount)
 
 	// Write the basic field attribute
 	buf.WriteByte(0xc0)
-	buf.WriteByte(sfAttribute(f.Write, f.Intense, f.Hidden, f.Autoskip,
-		f.NumericOnly))
+	buf.WriteByte(fieldAttribute(f))
 
 	// Write the highlighting attribute
 	if f.Highlighting != DefaultHighlight {
@@ -231,9 +1411,50 @@ func buildField(f Field) []byte {
 		buf.WriteByte(byte(f.Color))
 	}
 
+	// Write the field validation attribute
+	if f.MandatoryFill || f.MandatoryEntry || f.Trigger {
+		var validation byte
+		if f.MandatoryFill {
+			validation |= 1 << 2 // set "bit 5"
+		}
+		if f.MandatoryEntry {
+			validation |= 1 << 1 // set "bit 6"
+		}
+		if f.Trigger {
+			validation |= 1 // set "bit 7"
+		}
+		buf.WriteByte(0xc1)
+		buf.WriteByte(validation)
+	}
+
+	// Write the field outlining attribute
+	if f.Outline != NoOutline {
+		buf.WriteByte(0xc2)
+		buf.WriteByte(byte(f.Outline))
+	}
+
 	return buf.Bytes()
 }
 
+// fieldAttribute builds the basic field attribute byte for the field,
+// including the detectable and modified bits for light pen fields.
+func fieldAttribute(f Field) byte {
+	attribute := sfAttribute(f.Write, f.Intense, f.Hidden, f.Autoskip,
+		f.NumericOnly)
+	if f.PenDesignator == NoPen {
+		return attribute
+	}
+
+	bits := byte(decodes[attribute])
+	if bits&0x0c == 0 {
+		bits |= 1 << 2 // set "bit 5": normal intensity, detectable
+	}
+	if f.PenDesignator == PenSelected {
+		bits |= 1 // set "bit 7": a selected field has its MDT set
+	}
+	return codes[bits]
+}
+
 // sfAttribute builds the attribute byte for the "start field" 3270 command
 func sfAttribute(write, intense, hidden, skip, numeric bool) byte {
 	var attribute byte
@@ -264,17 +1485,24 @@ func sfAttribute(write, intense, hidden, skip, numeric bool) byte {
 
 // ic is the "insert cursor" 3270 command. This function will include the
 // appropriate SBA command.
-func ic(row, col int) []byte {
+func ic(row, col int, size screenSize) []byte {
 	result := make([]byte, 0, 3)
-	result = append(result, sba(row, col)...)
+	result = append(result, sba(row, col, size)...)
 	result = append(result, 0x13) // IC
 	return result
 }
 
 // getpos translates row and col to buffer address control characters.
-func getpos(row, col int) []byte {
+// Addresses beyond the range of 12-bit addressing (4095) are encoded as
+// 14-bit addresses.
+func getpos(row, col int, size screenSize) []byte {
 	result := make([]byte, 2)
-	address := row*80 + col
+	address := size.addr(row, col)
+	if address > 0xfff {
+		result[0] = byte(address >> 8 & 0x3f)
+		result[1] = byte(address)
+		return result
+	}
 	hi := (address & 0xfc0) >> 6
 	lo := address & 0x3f
 	result[0] = codes[hi]
@@ -0,0 +1,857 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildFieldValidation(t *testing.T) {
+	f := Field{Write: true, MandatoryFill: true, MandatoryEntry: true}
+	expected := []byte{0x29, 0x02, 0xc0, 0xc1, 0xc1, 0x06}
+	if result := buildField(f); !bytes.Equal(result, expected) {
+		t.Errorf("mandatory fill+entry field: expected %x, got %x",
+			expected, result)
+	}
+
+	f = Field{Write: true, Color: Red, MandatoryEntry: true}
+	expected = []byte{0x29, 0x03, 0xc0, 0xc1, 0x42, 0xf2, 0xc1, 0x02}
+	if result := buildField(f); !bytes.Equal(result, expected) {
+		t.Errorf("mandatory entry field with color: expected %x, got %x",
+			expected, result)
+	}
+}
+
+func TestFieldCursor(t *testing.T) {
+	screen := Screen{
+		{Row: 4, Col: 0, Content: "Name"},
+		{Row: 4, Col: 19, Name: "name", Write: true},
+		{Row: 4, Col: 30},
+	}
+
+	if row, col, ok := fieldCursor(screen, "name", 3, defaultSize); !ok ||
+		row != 4 || col != 23 {
+		t.Errorf("offset 3: expected (4, 23), got (%d, %d) %v", row, col, ok)
+	}
+
+	// The field has 10 positions, so the offset is clamped to 9
+	if row, col, ok := fieldCursor(screen, "name", 50, defaultSize); !ok ||
+		row != 4 || col != 29 {
+		t.Errorf("offset 50: expected (4, 29), got (%d, %d) %v", row, col, ok)
+	}
+
+	if _, _, ok := fieldCursor(screen, "missing", 0, defaultSize); ok {
+		t.Error("expected missing field to not be found")
+	}
+}
+
+func TestCursorAfterField(t *testing.T) {
+	screen := Screen{
+		{Row: 4, Col: 19, Name: "name", Write: true, Content: "x"},
+		{Row: 4, Col: 30},
+	}
+
+	orders := cursorOrders(screen, map[string]string{"name": "abc  "},
+		ScreenOpts{CursorAfterField: "name"})
+	if expected := ic(4, 23, defaultSize); !bytes.Equal(orders, expected) {
+		t.Errorf("expected %x, got %x", expected, orders)
+	}
+
+	// Content longer than the field is clamped to its last position
+	orders = cursorOrders(screen, map[string]string{"name": "abcdefghijkl"},
+		ScreenOpts{CursorAfterField: "name"})
+	if expected := ic(4, 29, defaultSize); !bytes.Equal(orders, expected) {
+		t.Errorf("expected %x, got %x", expected, orders)
+	}
+}
+
+func TestPadToWidth(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "msg", PadToWidth: true},
+		{Row: 0, Col: 6},
+	}
+	var b bytes.Buffer
+	buildFields(&b, screen, map[string]string{"msg": "ab"}, defaultSize)
+
+	// SBA, SF, "ab" padded with EBCDIC spaces to 5 positions, then SBA, SF
+	expected := []byte{0x11, 0x40, 0x40, 0x1d, 0x60, 0x81, 0x82, 0x40, 0x40,
+		0x40, 0x11, 0x40, 0xc6, 0x1d, 0x60}
+	if !bytes.Equal(b.Bytes(), expected) {
+		t.Errorf("expected %x, got %x", expected, b.Bytes())
+	}
+}
+
+func TestBuildDatastreamWriteOnly(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Content: "A"},
+		{Row: 0, Col: 2, Name: "in", Write: true},
+	}
+	datastream, fm := buildDatastream(screen, nil,
+		ScreenOpts{EraseMode: WriteOnly, CursorRow: 0, CursorCol: 3})
+
+	expected := []byte{
+		0xf1, 0xc3, // Write, WCC
+		0x11, 0x40, 0x40, 0x1d, 0x60, 0xc1, // SBA (0,0), SF, "A"
+		0x11, 0x40, 0xc2, 0x1d, 0xc1, // SBA (0,2), SF writable+MDT
+		0x11, 0x40, 0xc3, 0x13, // SBA (0,3), IC
+		0xff, 0xef, // IAC EOR
+	}
+	if !bytes.Equal(datastream, expected) {
+		t.Errorf("expected %x, got %x", expected, datastream)
+	}
+	if fm[3] != "in" {
+		t.Errorf("expected field \"in\" at address 3, got fieldmap %v", fm)
+	}
+}
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		content  string
+		width    int
+		expected string
+	}{
+		{"Matthew", 10, "Matthew"},
+		{"Matthew", 4, "Matt"},
+		{"Zoë Smith", 3, "Zo"}, // don't split the 2-byte ë
+		{"Zoë Smith", 4, "Zoë"},
+	}
+	for _, test := range tests {
+		if result := truncateContent(test.content,
+			test.width); result != test.expected {
+			t.Errorf("truncateContent(%q, %d): expected %q, got %q",
+				test.content, test.width, test.expected, result)
+		}
+	}
+}
+
+func TestValidateNormalize(t *testing.T) {
+	screen := Screen{
+		{Row: 1, Col: 0, Content: "Name"},
+		{Row: 1, Col: 5, Name: "name", Write: true},
+		{Row: 2, Col: 5, Name: "stopped", Write: true},
+		{Row: 2, Col: 20},
+		{Row: 3, Col: 70, Name: "eol", Write: true},
+		{Row: 4, Col: 0},
+	}
+
+	errs := screen.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+
+	normalized := screen.Normalize()
+	if len(normalized) != len(screen)+1 {
+		t.Fatalf("expected 1 field to be added, got %d",
+			len(normalized)-len(screen))
+	}
+	added := normalized[len(normalized)-1]
+	if added.Row != 1 || added.Col != 79 || added.Write {
+		t.Errorf("unexpected stop field %+v", added)
+	}
+	if errs := normalized.Validate(); errs != nil {
+		t.Errorf("expected no errors after Normalize, got %v", errs)
+	}
+}
+
+func TestValidateContentWrap(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 70, Content: "123456789"},
+		{Row: 1, Col: 70, Content: "1234567890"},
+		{Row: 2, Col: 70, Content: "1234567890", MaxWidth: 9},
+	}
+	errs := screen.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+}
+
+func TestValidateRawContentPadding(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, RawContent: []byte{0xc1}, PadToWidth: true},
+		{Row: 1, Col: 0, RawContent: []byte{0xc1}, Justify: JustifyRight},
+		{Row: 2, Col: 0, RawContent: []byte{0xc1}},
+		{Row: 3, Col: 0, Content: "A", PadToWidth: true},
+	}
+	errs := screen.Validate()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+
+	// Raw content is sent as-is, followed by the cursor position
+	datastream, _ := buildDatastream(screen[:1], nil, ScreenOpts{})
+	if !bytes.Contains(datastream, []byte{0x1d, 0x60, 0xc1, 0x11}) {
+		t.Errorf("expected the raw content unpadded, got %x", datastream)
+	}
+}
+
+func TestBell(t *testing.T) {
+	conn := &recordingTransport{}
+	if err := Bell(conn); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0xf1, 0xc4, iac, eor}
+	if !bytes.Equal(conn.written.Bytes(), expected) {
+		t.Errorf("expected %x, got %x", expected, conn.written.Bytes())
+	}
+
+	// The alarm doesn't unlock the keyboard as a side effect
+	const restore, alarm = 0x02, 0x04
+	if wccAlarm&alarm == 0 || wccAlarm&restore != 0 {
+		t.Errorf("expected WCC %02x to sound the alarm only", wccAlarm)
+	}
+}
+
+func TestSendScreen(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "name", Write: true},
+		{Row: 0, Col: 10},
+	}
+	conn := &recordingTransport{in: bytes.NewReader(nil)}
+
+	pending, err := SendScreen(screen, nil, conn, ScreenOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.written.Len() == 0 {
+		t.Error("expected the screen to be written")
+	}
+
+	// Enter with the cursor at address 1, and "AB" in the field, arriving
+	// in pieces that split the telnet EOR, followed by the start of the
+	// next record.
+	chunks := [][]byte{
+		{0x7d, 0x40},
+		{0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xc2, iac},
+		{eor, 0xf3},
+	}
+	for i, chunk := range chunks {
+		resp, done, rest, err := pending.Feed(chunk)
+		if i < len(chunks)-1 {
+			if done || err != nil {
+				t.Fatalf("chunk %d: expected more data to be needed, got "+
+					"done=%v err=%v", i, done, err)
+			}
+			continue
+		}
+		if !done || err != nil {
+			t.Fatalf("expected a complete response, got done=%v err=%v",
+				done, err)
+		}
+		if resp.AID != AIDEnter || resp.Values["name"] != "AB" ||
+			resp.CursorField != "name" {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		if !bytes.Equal(rest, []byte{0xf3}) {
+			t.Errorf("expected the next record's data as rest, got %x",
+				rest)
+		}
+	}
+}
+
+func TestSendScreenShortRecord(t *testing.T) {
+	conn := &recordingTransport{in: bytes.NewReader(nil)}
+	pending, err := SendScreen(Screen{{Row: 0, Col: 0, Content: "hi"}}, nil,
+		conn, ScreenOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, done, _, err := pending.Feed([]byte{0x40, iac, eor})
+	if !done || err != ErrShortRecord {
+		t.Errorf("expected ErrShortRecord, got done=%v err=%v", done, err)
+	}
+}
+
+func TestRecordEnd(t *testing.T) {
+	tests := []struct {
+		data []byte
+		end  int
+	}{
+		{[]byte{0x7d, iac, eor}, 3},
+		{[]byte{0x7d, iac, iac, eor}, -1}, // an escaped 0xff, then data
+		{[]byte{iac, do, eor, 0x7d, iac, eor, 0x40}, 6},
+		{[]byte{iac, sb, terminalType, iac, eor, iac, se, iac, eor}, 9},
+		{[]byte{0x7d, iac}, -1},
+	}
+	for _, test := range tests {
+		if end := recordEnd(test.data); end != test.end {
+			t.Errorf("%x: expected %d, got %d", test.data, test.end, end)
+		}
+	}
+}
+
+func TestPenDesignator(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "a", Content: "ONE", PenDesignator: PenSelect},
+		{Row: 1, Col: 0, Name: "b", Content: "TWO",
+			PenDesignator: PenSelected},
+		{Row: 2, Col: 0, Name: "go", Content: "GO",
+			PenDesignator: PenAttention},
+	}
+
+	datastream, _ := buildDatastream(screen, nil, ScreenOpts{})
+	expected := []byte{0x11, 0x40, 0x40, 0x1d, 0xe4, 0x6f, 0xd6, 0xd5, 0xc5}
+	if !bytes.Equal(datastream[2:2+len(expected)], expected) {
+		t.Errorf("expected %x, got %x", expected,
+			datastream[2:2+len(expected)])
+	}
+	if datastream[2+len(expected)+4] != 0xe5 {
+		t.Errorf("expected selected attribute e5, got %02x",
+			datastream[2+len(expected)+4])
+	}
+
+	// Selector pen attention on "go" at address 161, with "b" (address 81)
+	// selected
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7e, 0xc2, 0x61, 0x11, 0xc1, 0xd1, 0xff, 0xef})}
+	resp, err := ShowScreen(screen, nil, 0, 0, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDSelectorPen || len(resp.PenSelections) != 1 ||
+		resp.PenSelections[0] != "b" || len(resp.Values) != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestTransientField(t *testing.T) {
+	screen := Screen{{Row: 0, Col: 0, Name: "msg", Transient: true},
+		{Row: 1, Col: 0, Name: "keep"}}
+	values := map[string]string{"keep": "x"}
+
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0x40, 0xff, 0xef, 0x7d, 0x40, 0x40, 0xff, 0xef})}
+	session := NewScreenSession(conn)
+	session.SetTransient("msg", "Saved.")
+
+	if _, err := session.ShowScreen(screen, values, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.written.Bytes(), a2e([]byte("Saved."))) {
+		t.Error("expected the transient message to be sent")
+	}
+
+	// The message is only displayed once
+	conn.written.Reset()
+	if _, err := session.ShowScreen(screen, values, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(conn.written.Bytes(), a2e([]byte("Saved."))) {
+		t.Error("expected the transient message to be sent only once")
+	}
+
+	if len(values) != 1 || values["keep"] != "x" {
+		t.Errorf("expected the values map to be unchanged, got %v", values)
+	}
+}
+
+func TestPartial(t *testing.T) {
+	base := Screen{
+		{Row: 0, Col: 0, Content: "Count:"},
+		{Row: 0, Col: 7, Name: "count"},
+		{Row: 0, Col: 12, Content: "Status:"},
+		{Row: 0, Col: 20, Name: "status"},
+		{Row: 0, Col: 30},
+	}
+
+	partial := base.Partial(map[string]string{"count": "7", "other": "x"})
+	if len(partial) != 1 {
+		t.Fatalf("expected 1 field, got %d", len(partial))
+	}
+	if partial[0].Row != 0 || partial[0].Col != 7 ||
+		partial[0].Content != "7   " {
+		t.Errorf("unexpected field %+v", partial[0])
+	}
+}
+
+func TestJustifyRight(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "n", Justify: JustifyRight},
+		{Row: 0, Col: 6},
+		{Row: 1, Col: 0, Name: "m", Justify: JustifyRight, MaxWidth: 3},
+	}
+	var b bytes.Buffer
+	buildFields(&b, screen, map[string]string{"n": "42 ", "m": "7"},
+		defaultSize)
+
+	expected := append([]byte{0x11, 0x40, 0x40, 0x1d, 0x60},
+		a2e([]byte("   42"))...)
+	if !bytes.HasPrefix(b.Bytes(), expected) {
+		t.Errorf("expected prefix %x, got %x", expected, b.Bytes())
+	}
+	if !bytes.HasSuffix(b.Bytes(), a2e([]byte("  7"))) {
+		t.Errorf("expected MaxWidth to limit justification, got %x",
+			b.Bytes())
+	}
+}
+
+func TestClearFields(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "cmd", Write: true},
+		{Row: 0, Col: 10},
+	}
+	datastream, _ := buildDatastream(screen, nil, ScreenOpts{
+		EraseMode: WriteOnly, ClearFields: []string{"cmd", "missing"},
+		NoCursorMove: true})
+
+	// Write, WCC, SBA to address 1, RA to address 10 with nulls
+	expected := []byte{0xf1, 0xc3, 0x11, 0x40, 0xc1, 0x3c, 0x40, 0x4a, 0x00,
+		0x11, 0x40, 0x40}
+	if !bytes.HasPrefix(datastream, expected) {
+		t.Errorf("expected prefix %x, got %x", expected, datastream)
+	}
+}
+
+func TestScreenSize(t *testing.T) {
+	opts := ScreenOpts{Rows: 27, Cols: 132}
+	size := opts.size()
+	screen := Screen{
+		{Row: 26, Col: 100, Name: "name", Write: true},
+		{Row: 26, Col: 110},
+	}
+
+	// Enter with the cursor at (26, 103), and "AB" in the field
+	inbound := []byte{0x7d}
+	inbound = append(inbound, getpos(26, 103, size)...)
+	inbound = append(inbound, sba(26, 101, size)...)
+	inbound = append(inbound, 0xc1, 0xc2, 0xff, 0xef)
+	conn := &recordingTransport{in: bytes.NewReader(inbound)}
+
+	resp, err := ShowScreenOpts(screen, nil, conn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.written.Bytes()[0] != 0x7e {
+		t.Errorf("expected Erase/Write Alternate, got command %02x",
+			conn.written.Bytes()[0])
+	}
+	if resp.Row != 26 || resp.Col != 103 || resp.Values["name"] != "AB" ||
+		resp.CursorField != "name" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	_, err = ShowScreenOpts(screen, nil, &recordingTransport{},
+		ScreenOpts{Rows: 200, Cols: 200})
+	if err != ErrScreenSize {
+		t.Errorf("expected ErrScreenSize, got %v", err)
+	}
+}
+
+func TestScreenSizeHelpers(t *testing.T) {
+	opts := ScreenOpts{Rows: 27, Cols: 132}
+	screen := Screen{
+		{Row: 26, Col: 100, Name: "status"},
+		{Row: 1, Col: 90, Name: "name", Write: true},
+		{Row: 1, Col: 85, Content: "Name"},
+	}
+
+	sorted := screen.Sorted()
+	if sorted[0].Col != 85 || sorted[1].Col != 90 || sorted[2].Row != 26 {
+		t.Errorf("unexpected order %+v", sorted)
+	}
+
+	partial := screen.PartialOpts(map[string]string{"status": "OK"}, opts)
+	// Padded to the end of the 132 column screen and around to (1,85)
+	if len(partial) != 1 || len(partial[0].Content) != 31+132+85 {
+		t.Errorf("expected status padded to the next field, got %+v",
+			partial)
+	}
+
+	// The input area runs to the end of the 132 column line
+	if errs := screen.ValidateOpts(opts); len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	normalized := screen.NormalizeOpts(opts)
+	if stop := normalized[len(normalized)-1]; stop.Row != 1 ||
+		stop.Col != 131 {
+		t.Errorf("expected a stop field at (1,131), got (%d,%d)", stop.Row,
+			stop.Col)
+	}
+	if errs := normalized.ValidateOpts(opts); errs != nil {
+		t.Errorf("expected no errors after normalizing, got %v", errs)
+	}
+}
+
+func TestSessionUpdateScreenSize(t *testing.T) {
+	opts := ScreenOpts{Rows: 27, Cols: 132}
+	screen := Screen{
+		{Row: 1, Col: 90, Name: "name", Write: true},
+		{Row: 1, Col: 100},
+	}
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x6d, 0xff, 0xef})} // Clear
+	session := NewScreenSession(conn)
+	if _, err := session.ShowScreenOpts(screen, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// A field on the input field's attribute is a conflict
+	err := session.Update(Screen{{Row: 1, Col: 90}}, nil)
+	if err != ErrUpdateConflict {
+		t.Errorf("expected ErrUpdateConflict, got %v", err)
+	}
+
+	// A field beyond row 24 and column 80 is written
+	conn.written.Reset()
+	err = session.Update(Screen{{Row: 26, Col: 100, Content: "OK"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.written.Bytes(), sba(26, 100, opts.size())) {
+		t.Errorf("expected the field at (26,100), got %x",
+			conn.written.Bytes())
+	}
+}
+
+func TestSessionChanged(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "first", Write: true},
+		{Row: 0, Col: 10},
+		{Row: 0, Col: 20, Name: "last", Write: true},
+		{Row: 0, Col: 30},
+		{Row: 0, Col: 40, Name: "notes", Write: true},
+		{Row: 0, Col: 50},
+	}
+	values := map[string]string{"first": "ALICE", "last": "SMITH"}
+
+	// Enter with first changed to BOB, and last and notes as sent
+	in := []byte{0x7d, 0x40, 0x40}
+	in = append(in, sba(0, 1, defaultSize)...)
+	in = append(in, a2e([]byte("BOB"))...)
+	in = append(in, sba(0, 21, defaultSize)...)
+	in = append(in, a2e([]byte("SMITH"))...)
+	in = append(in, sba(0, 41, defaultSize)...)
+	in = append(in, iac, eor)
+	conn := &recordingTransport{in: bytes.NewReader(in)}
+
+	resp, err := NewScreenSession(conn).ShowScreen(screen, values, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"first": true, "last": false,
+		"notes": false}
+	for name, changed := range expected {
+		if got, ok := resp.Changed[name]; !ok || got != changed {
+			t.Errorf("%s: expected changed=%v, got %v (present=%v)", name,
+				changed, got, ok)
+		}
+	}
+}
+
+// failingTransport is a recordingTransport whose writes fail while fail is
+// set.
+type failingTransport struct {
+	*recordingTransport
+	fail bool
+}
+
+func (t *failingTransport) Write(b []byte) (int, error) {
+	if t.fail {
+		return 0, io.ErrClosedPipe
+	}
+	return t.recordingTransport.Write(b)
+}
+
+func TestSessionWriteFailure(t *testing.T) {
+	conn := &failingTransport{recordingTransport: &recordingTransport{
+		in: bytes.NewReader([]byte{0x6d, 0xff, 0xef})}} // Clear
+	session := NewScreenSession(conn)
+	_, err := session.ShowScreen(Screen{
+		{Row: 1, Col: 0, Name: "name", Write: true},
+		{Row: 1, Col: 20},
+		{Row: 2, Col: 0, Name: "msg", Transient: true},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A screen that isn't written replaces nothing the session recorded
+	conn.fail = true
+	session.SetTransient("msg", "Saved.")
+	_, err = session.ShowScreen(Screen{
+		{Row: 5, Col: 0, Name: "other", Write: true},
+		{Row: 5, Col: 20},
+		{Row: 6, Col: 0, Name: "msg", Transient: true},
+	}, nil, 0, 0)
+	if err != io.ErrClosedPipe {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	conn.fail = false
+
+	if err := session.Update(Screen{{Row: 1, Col: 0}}, nil); err !=
+		ErrUpdateConflict {
+		t.Errorf("expected the first screen's input field to conflict, "+
+			"got %v", err)
+	}
+	if err := session.Update(Screen{{Row: 5, Col: 0}}, nil); err != nil {
+		t.Errorf("expected no conflict with the unsent screen, got %v", err)
+	}
+
+	// The transient message is still pending
+	conn.in = bytes.NewReader([]byte{0x6d, 0xff, 0xef})
+	conn.written.Reset()
+	_, err = session.ShowScreen(Screen{
+		{Row: 6, Col: 0, Name: "msg", Transient: true}}, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.written.Bytes(), a2e([]byte("Saved."))) {
+		t.Error("expected the transient message to be sent")
+	}
+}
+
+func TestSoundAlarm(t *testing.T) {
+	datastream, _ := buildDatastream(nil, nil, ScreenOpts{SoundAlarm: true})
+	if datastream[0] != 0xf5 || datastream[1] != 0xc7 {
+		t.Errorf("expected Erase/Write with WCC c7, got %x", datastream[:2])
+	}
+
+	datastream, _ = buildDatastream(nil, nil,
+		ScreenOpts{SoundAlarm: true, EraseMode: WriteOnly})
+	if datastream[0] != 0xf1 || datastream[1] != 0xc7 {
+		t.Errorf("expected Write with WCC c7, got %x", datastream[:2])
+	}
+}
+
+func TestWCCOverride(t *testing.T) {
+	wcc := byte(0x40) // keyboard left locked
+	for _, test := range []struct {
+		opts     ScreenOpts
+		expected []byte
+	}{
+		{ScreenOpts{}, []byte{0xf5, 0xc3}},
+		{ScreenOpts{WCCOverride: &wcc}, []byte{0xf5, 0x40}},
+		{ScreenOpts{WCCOverride: &wcc, EraseMode: WriteOnly},
+			[]byte{0xf1, 0x40}},
+		{ScreenOpts{WCCOverride: &wcc, SoundAlarm: true},
+			[]byte{0xf5, 0x44}},
+	} {
+		datastream, _ := buildDatastream(Screen{{Row: 0, Col: 0}}, nil,
+			test.opts)
+		if !bytes.HasPrefix(datastream, test.expected) {
+			t.Errorf("expected %x, got %x", test.expected, datastream[:2])
+		}
+	}
+
+	// The override is what ShowScreenOpts() writes
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x6d, 0xff, 0xef})} // Clear
+	_, err := ShowScreenOpts(Screen{{Row: 0, Col: 0}}, nil, conn,
+		ScreenOpts{WCCOverride: &wcc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); written[1] != wcc {
+		t.Errorf("expected WCC %02x to be sent, got %x", wcc, written)
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	screen := Screen{
+		{Row: 5, Col: 0, Name: "last", Write: true},
+		{Row: 0, Col: 0, Name: "title"},
+		{Row: 1, Col: 0, Name: "first", Write: true},
+		{Row: 2, Col: 0, Content: "unnamed", Write: true},
+		{Row: 3, Col: 0, Name: "title"},
+		{Row: 4, Col: 0, Name: "first", Write: true},
+	}
+
+	// Screen order, not position order
+	expected := []string{"last", "first", "first"}
+	if got := screen.WritableFields(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected writable fields %v, got %v", expected, got)
+	}
+	expected = []string{"last", "title", "first"}
+	if got := screen.NamedFields(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected named fields %v, got %v", expected, got)
+	}
+	if Screen(nil).WritableFields() != nil || Screen(nil).NamedFields() != nil {
+		t.Error("expected no names for an empty screen")
+	}
+}
+
+func TestProtectAll(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Name: "name", Write: true, Color: Green},
+		{Row: 0, Col: 20},
+		{Row: 1, Col: 0, Name: "code", Write: true, NumericOnly: true},
+		{Row: 1, Col: 20, Content: "label"},
+	}
+
+	protected := screen.ProtectAll()
+	if len(protected) != len(screen) {
+		t.Fatalf("expected %d fields, got %d", len(screen), len(protected))
+	}
+	for i, fld := range protected {
+		if fld.Write {
+			t.Errorf("field %d: expected it to be protected", i)
+		}
+		fld.Write = screen[i].Write
+		if !reflect.DeepEqual(fld, screen[i]) {
+			t.Errorf("field %d: expected only Write to change, got %+v", i,
+				fld)
+		}
+	}
+	if _, fm := buildDatastream(protected, nil, ScreenOpts{}); len(fm) != 0 {
+		t.Errorf("expected no input fields, got %v", fm)
+	}
+
+	// The caller's screen is unchanged
+	if !screen[0].Write || !screen[2].Write {
+		t.Error("expected the original screen to be unchanged")
+	}
+}
+
+func TestColorMap(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Content: "A", Color: Yellow},
+		{Row: 1, Col: 0, Content: "B", Color: Green},
+	}
+
+	result := resolveFallbacks(screen, ScreenOpts{ColorMap: FourColorMap})
+	if result[0].Color != White || result[1].Color != Green {
+		t.Errorf("unexpected colors %x, %x", result[0].Color,
+			result[1].Color)
+	}
+	if screen[0].Color != Yellow {
+		t.Error("original screen was modified")
+	}
+}
+
+func TestOutline(t *testing.T) {
+	f := Field{Write: true, Color: Green, Outline: OutlineBox}
+	expected := []byte{0x29, 0x03, 0xc0, 0xc1, 0x42, 0xf4, 0xc2, 0x0f}
+	if got := buildField(f); !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+
+	f = Field{Outline: OutlineUnder}
+	expected = []byte{0x29, 0x02, 0xc0, 0x60, 0xc2, 0x01}
+	if got := buildField(f); !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+}
+
+func TestDatastreamSize(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Content: "Title", Color: Blue,
+			Fallback: &Field{Row: 0, Col: 0, Content: "Title",
+				Intense: true}},
+		{Row: 1, Col: 0, Name: "name", Write: true, Content: "default"},
+		{Row: 1, Col: 30, RawContent: []byte{0xc1, iac, 0xc2}},
+	}
+	values := map[string]string{"name": "override"}
+
+	for i, opts := range []ScreenOpts{
+		{},
+		{NoExtended: true},
+		{Rows: 32, Cols: 80, CursorField: "name"},
+		{EraseMode: WriteOnly, SoundAlarm: true},
+	} {
+		conn := &recordingTransport{in: bytes.NewReader(
+			[]byte{0x6d, iac, eor})} // Clear
+		if _, err := ShowScreenOpts(screen, values, conn, opts); err != nil {
+			t.Fatalf("opts %d: %v", i, err)
+		}
+		// The IAC in the raw content is escaped on the wire
+		if !bytes.Contains(conn.written.Bytes(), []byte{0xc1, iac, iac}) {
+			t.Errorf("opts %d: expected an escaped IAC in %x", i,
+				conn.written.Bytes())
+		}
+		if size := DatastreamSize(screen, values, opts); size !=
+			conn.written.Len() {
+			t.Errorf("opts %d: DatastreamSize %d, but %d bytes written", i,
+				size, conn.written.Len())
+		}
+	}
+}
+
+func TestShowScreenContext(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(ioutil.Discard, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := ShowScreenContext(ctx, Screen{{Row: 0, Col: 0, Content: "hi"}},
+		nil, server, ScreenOpts{})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The connection is still usable after cancellation
+	go client.Write([]byte{0x7d, 0x40, 0x40, iac, eor})
+	resp, err := ShowScreenContext(context.Background(),
+		Screen{{Row: 0, Col: 0, Content: "hi"}}, nil, server, ScreenOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter {
+		t.Errorf("expected Enter, got %s", AIDtoString(resp.AID))
+	}
+}
+
+func TestShowScreenContextDeadline(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(ioutil.Discard, client)
+
+	// The earlier of the context's deadline and InputTimeout applies
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ShowScreenContext(ctx, Screen{{Row: 0, Col: 0, Content: "hi"}},
+		nil, server, ScreenOpts{InputTimeout: 10 * time.Second})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the context's deadline, took %v", elapsed)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = ShowScreenContext(ctx, Screen{{Row: 0, Col: 0, Content: "hi"}},
+		nil, server, ScreenOpts{InputTimeout: 50 * time.Millisecond})
+	if err != ErrInputTimeout {
+		t.Errorf("expected ErrInputTimeout, got %v", err)
+	}
+
+	// Cancelling isn't undone by the InputTimeout deadline
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	_, err = ShowScreenContext(ctx, Screen{{Row: 0, Col: 0, Content: "hi"}},
+		nil, server, ScreenOpts{InputTimeout: 10 * time.Second})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected cancellation to end the wait, took %v", elapsed)
+	}
+}
+
+func TestInputTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go io.Copy(ioutil.Discard, client)
+
+	_, err := ShowScreenOpts(Screen{{Row: 0, Col: 0, Content: "hi"}}, nil,
+		server, ScreenOpts{InputTimeout: 50 * time.Millisecond})
+	if err != ErrInputTimeout {
+		t.Fatalf("expected ErrInputTimeout, got %v", err)
+	}
+}
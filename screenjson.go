@@ -0,0 +1,110 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var colorNames = map[Color]string{
+	DefaultColor: "default",
+	Blue:         "blue",
+	Red:          "red",
+	Pink:         "pink",
+	Green:        "green",
+	Turquoise:    "turquoise",
+	Yellow:       "yellow",
+	White:        "white",
+}
+
+var highlightNames = map[Highlight]string{
+	DefaultHighlight: "default",
+	Blink:            "blink",
+	ReverseVideo:     "reverse",
+	Underscore:       "underscore",
+}
+
+// MarshalJSON encodes the color as its name, such as "green".
+func (c Color) MarshalJSON() ([]byte, error) {
+	name, ok := colorNames[c]
+	if !ok {
+		return nil, fmt.Errorf("go3270: unknown color %#02x", byte(c))
+	}
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON decodes a color name, in any case, or a color's numeric
+// value.
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var value byte
+		if json.Unmarshal(data, &value) != nil {
+			return err
+		}
+		*c = Color(value)
+		return nil
+	}
+	for color, n := range colorNames {
+		if strings.EqualFold(name, n) {
+			*c = color
+			return nil
+		}
+	}
+	return fmt.Errorf("go3270: unknown color %q", name)
+}
+
+// MarshalJSON encodes the highlighting as its name, such as "underscore".
+func (h Highlight) MarshalJSON() ([]byte, error) {
+	name, ok := highlightNames[h]
+	if !ok {
+		return nil, fmt.Errorf("go3270: unknown highlight %#02x", byte(h))
+	}
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON decodes a highlighting name, in any case, or a
+// highlighting's numeric value.
+func (h *Highlight) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var value byte
+		if json.Unmarshal(data, &value) != nil {
+			return err
+		}
+		*h = Highlight(value)
+		return nil
+	}
+	for highlight, n := range highlightNames {
+		if strings.EqualFold(name, n) {
+			*h = highlight
+			return nil
+		}
+	}
+	return fmt.Errorf("go3270: unknown highlight %q", name)
+}
+
+// LoadScreenJSON reads a screen definition written by Screen.WriteJSON():
+// a JSON array of objects with the members of Field. Members that are
+// omitted have their zero value. This allows screens to be kept in files
+// and loaded at runtime.
+func LoadScreenJSON(r io.Reader) (Screen, error) {
+	var screen Screen
+	if err := json.NewDecoder(r).Decode(&screen); err != nil {
+		return nil, err
+	}
+	return screen, nil
+}
+
+// WriteJSON writes the screen definition to w as indented JSON, to be read
+// by LoadScreenJSON(). Colors and highlighting are written by name.
+func (s Screen) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,55 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScreenJSON(t *testing.T) {
+	screen := Screen{
+		{Row: 0, Col: 0, Content: "Title", Color: White,
+			Highlighting: ReverseVideo},
+		{Row: 1, Col: 0, Name: "name", Write: true, Color: Green,
+			Highlighting: Underscore,
+			Fallback:     &Field{Row: 1, Col: 0, Name: "name", Write: true}},
+		{Row: 1, Col: 20},
+	}
+
+	var b bytes.Buffer
+	if err := screen.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"Color": "green"`) ||
+		!strings.Contains(b.String(), `"Highlighting": "underscore"`) {
+		t.Errorf("expected named colors and highlights, got %s", b.String())
+	}
+
+	loaded, err := LoadScreenJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, screen) {
+		t.Errorf("expected %+v, got %+v", screen, loaded)
+	}
+
+	loaded, err = LoadScreenJSON(strings.NewReader(
+		`[{"Row": 2, "Color": "Blue", "Highlighting": 241}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded[0].Row != 2 || loaded[0].Color != Blue ||
+		loaded[0].Highlighting != Blink {
+		t.Errorf("unexpected field %+v", loaded[0])
+	}
+
+	if _, err := LoadScreenJSON(strings.NewReader(
+		`[{"Color": "mauve"}]`)); err == nil {
+		t.Error("expected an error for an unknown color")
+	}
+}
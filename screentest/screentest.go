@@ -0,0 +1,55 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+// Package screentest provides helpers for testing go3270 screen layouts
+// against golden text fixtures.
+package screentest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/racingmars/go3270"
+	"github.com/racingmars/go3270/render"
+)
+
+// UpdateEnv is the environment variable that, when set to a non-empty value,
+// causes AssertScreen to write the rendered screen to the fixture file
+// instead of comparing against it.
+const UpdateEnv = "GO3270_UPDATE_FIXTURES"
+
+// AssertScreen renders the screen, with override values, as 24x80 text
+// (see render.RenderText()) and fails the test if the result doesn't match
+// the contents of the file at fixturePath.
+//
+// To create or update fixtures, run the tests with the GO3270_UPDATE_FIXTURES
+// environment variable set, e.g.:
+//
+//	GO3270_UPDATE_FIXTURES=1 go test ./...
+func AssertScreen(t testing.TB, screen go3270.Screen,
+	values map[string]string, fixturePath string) {
+
+	t.Helper()
+	rendered := render.RenderText(screen, values, 24, 80)
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := ioutil.WriteFile(fixturePath, []byte(rendered),
+			0644); err != nil {
+			t.Fatalf("couldn't update fixture %s: %v", fixturePath, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(fixturePath)
+	if err != nil {
+		t.Fatalf("couldn't read fixture %s (set %s to create it): %v",
+			fixturePath, UpdateEnv, err)
+	}
+
+	if rendered != string(expected) {
+		t.Errorf("screen does not match fixture %s\n"+
+			"--- expected:\n%s--- got:\n%s", fixturePath, expected, rendered)
+	}
+}
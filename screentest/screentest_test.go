@@ -0,0 +1,27 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package screentest
+
+import (
+	"testing"
+
+	"github.com/racingmars/go3270"
+)
+
+func TestAssertScreen(t *testing.T) {
+	screen := go3270.Screen{
+		{Row: 0, Col: 27, Intense: true, Content: "3270 Example Application"},
+		{Row: 4, Col: 0, Content: "First Name  . . ."},
+		{Row: 4, Col: 19, Name: "fname", Write: true},
+		{Row: 4, Col: 40, Autoskip: true},
+		{Row: 6, Col: 0, Content: "Password  . . . ."},
+		{Row: 6, Col: 19, Name: "password", Write: true, Hidden: true},
+		{Row: 6, Col: 40, Autoskip: true},
+		{Row: 22, Col: 0, Content: "PF3 Exit"},
+	}
+	values := map[string]string{"fname": "Matthew", "password": "secret"}
+
+	AssertScreen(t, screen, values, "testdata/example.txt")
+}
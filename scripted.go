@@ -0,0 +1,60 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+// ScreenProvider supplies screen definitions and their validation rules by
+// name. It allows the screens in an application, and the flow between them,
+// to be defined outside of Go code; for example, by a scripting engine or by
+// data files.
+type ScreenProvider interface {
+	Screen(name string) (Screen, Rules, error)
+}
+
+// ScriptHandler is called by RunScripted() each time the user submits the
+// screen called name. It returns the name of the next screen to display and
+// the field values to display on it. Returning an empty nextName ends the
+// RunScripted() loop.
+type ScriptHandler func(name string, resp Response) (nextName string,
+	values map[string]string)
+
+// allAIDs are all of the AID keys a 3270 client can send, used when the
+// caller wants every key handled by its own logic.
+var allAIDs = []AID{AIDEnter, AIDPF1, AIDPF2, AIDPF3, AIDPF4, AIDPF5,
+	AIDPF6, AIDPF7, AIDPF8, AIDPF9, AIDPF10, AIDPF11, AIDPF12, AIDPF13,
+	AIDPF14, AIDPF15, AIDPF16, AIDPF17, AIDPF18, AIDPF19, AIDPF20, AIDPF21,
+	AIDPF22, AIDPF23, AIDPF24, AIDPA1, AIDPA2, AIDPA3, AIDClear}
+
+// RunScripted drives a series of screens, starting with the screen called
+// start, where the provider supplies each screen and the handler decides
+// which screen comes next. Each screen is displayed with HandleScreen(), so
+// the screen's Rules are enforced (with error messages written to the field
+// named errorField) before the handler is called. Every AID key is accepted;
+// it is up to the handler to act on Response.AID. The cursor is initially
+// placed at row 0, column 0 of each screen.
+//
+// RunScripted returns nil when the handler returns an empty screen name, or
+// the first error encountered from the provider or the connection.
+func RunScripted(conn Transport, provider ScreenProvider, start string,
+	errorField string, handler ScriptHandler) error {
+
+	name := start
+	var values map[string]string
+	for name != "" {
+		screen, rules, err := provider.Screen(name)
+		if err != nil {
+			return err
+		}
+
+		resp, err := HandleScreen(screen, rules, values, allAIDs, nil,
+			errorField, 0, 0, conn)
+		if err != nil {
+			return err
+		}
+
+		name, values = handler(name, resp)
+	}
+
+	return nil
+}
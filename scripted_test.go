@@ -0,0 +1,90 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// mapProvider is a ScreenProvider of screens kept in a map.
+type mapProvider map[string]Screen
+
+var errNoScreen = errors.New("no such screen")
+
+func (p mapProvider) Screen(name string) (Screen, Rules, error) {
+	screen, ok := p[name]
+	if !ok {
+		return nil, nil, errNoScreen
+	}
+	if name == "login" {
+		return screen, Rules{"user": {Validator: NonBlank}}, nil
+	}
+	return screen, nil, nil
+}
+
+func TestRunScripted(t *testing.T) {
+	provider := mapProvider{
+		"login": {
+			{Row: 0, Col: 0, Name: "user", Write: true},
+			{Row: 0, Col: 10},
+			{Row: 1, Col: 0, Name: "msg"},
+		},
+		"menu": {
+			{Row: 0, Col: 0, Name: "greeting"},
+			{Row: 1, Col: 0, Name: "msg"},
+		},
+	}
+
+	// Enter with user blank, which the rules reject; Enter with BOB; then
+	// PF3 on the menu
+	in := []byte{0x7d, 0x40, 0x40}
+	in = append(in, sba(0, 1, defaultSize)...)
+	in = append(in, iac, eor, 0x7d, 0x40, 0x40)
+	in = append(in, sba(0, 1, defaultSize)...)
+	in = append(in, a2e([]byte("BOB"))...)
+	in = append(in, iac, eor, 0xf3, 0x40, 0x40, iac, eor)
+	conn := &recordingTransport{in: bytes.NewReader(in)}
+
+	var calls []string
+	err := RunScripted(conn, provider, "login", "msg",
+		func(name string, resp Response) (string, map[string]string) {
+			calls = append(calls, name+" "+AIDtoString(resp.AID))
+			if name == "login" {
+				return "menu", map[string]string{
+					"greeting": "HELLO " + resp.Values["user"]}
+			}
+			return "", nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler only sees responses that pass the rules
+	if len(calls) != 2 || calls[0] != "login Enter" ||
+		calls[1] != "menu PF3" {
+		t.Errorf("unexpected handler calls %v", calls)
+	}
+	written := conn.written.Bytes()
+	if !bytes.Contains(written, a2e([]byte("HELLO BOB"))) {
+		t.Error("expected the handler's values on the menu")
+	}
+	if bytes.Count(written, []byte{iac, eor}) != 3 {
+		t.Errorf("expected 3 screens to be sent, got %x", written)
+	}
+}
+
+func TestRunScriptedProviderError(t *testing.T) {
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0x40, iac, eor})}
+	err := RunScripted(conn, mapProvider{"menu": {{Row: 0, Col: 0}}},
+		"menu", "", func(string, Response) (string, map[string]string) {
+			return "missing", nil
+		})
+	if err != errNoScreen {
+		t.Errorf("expected the provider's error, got %v", err)
+	}
+}
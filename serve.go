@@ -0,0 +1,70 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+// Serve accepts connections on l and handles each one in a new goroutine:
+// it negotiates the telnet options for tn3270 with NegotiateTelnet(), then
+// calls handler with the connection, ready for ShowScreen(). The connection
+// is closed when handler returns, and connections that fail negotiation
+// are closed without calling handler. Serve returns the error from
+// l.Accept(), such as when l is closed.
+//
+// l may be any net.Listener, including one from tls.Listen() or
+// tls.NewListener() to serve tn3270 over TLS: telnet negotiation, screens,
+// and read deadlines all work through a *tls.Conn.
+func Serve(l net.Listener, handler func(conn net.Conn)) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := NegotiateTelnet(conn); err != nil {
+				debugf("negotiation with %v failed: %v\n", conn.RemoteAddr(),
+					err)
+				return
+			}
+			handler(conn)
+		}()
+	}
+}
+
+// ListenAndServe listens on the TCP network address addr and calls Serve()
+// to handle the connections.
+func ListenAndServe(addr string, handler func(conn net.Conn)) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return Serve(l, handler)
+}
+
+// ListenAndServeTLS listens on the TCP network address addr for TLS
+// connections (often called tn3270s), and calls Serve() to handle them.
+// certFile and keyFile are the PEM-encoded certificate and private key, as
+// for tls.LoadX509KeyPair(). For other TLS settings, create the listener
+// with tls.Listen() and call Serve() directly.
+func ListenAndServeTLS(addr, certFile, keyFile string,
+	handler func(conn net.Conn)) error {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	l, err := tls.Listen("tcp", addr,
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return Serve(l, handler)
+}
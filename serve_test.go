@@ -0,0 +1,96 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate for 127.0.0.1.
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go3270 test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServeTLS(t *testing.T) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ready := make(chan struct{})
+	type screenResult struct {
+		Response Response
+		Err      error
+	}
+	result := make(chan screenResult, 1)
+	go Serve(l, func(conn net.Conn) {
+		close(ready)
+		resp, err := ShowScreen(Screen{{Row: 0, Col: 0, Content: "hi"}},
+			nil, 0, 0, conn)
+		result <- screenResult{Response: resp, Err: err}
+	})
+
+	client, err := tls.Dial("tcp", l.Addr().String(),
+		&tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	out := fakeTelnetClient(client)
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for negotiation")
+	}
+	out <- []byte{0x7d, 0x40, 0xc1, iac, eor}
+
+	select {
+	case r := <-result:
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Response.AID != AIDEnter {
+			t.Errorf("expected Enter, got %s", AIDtoString(r.Response.AID))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the response")
+	}
+}
+
+func TestListenAndServeTLSMissingCertificate(t *testing.T) {
+	err := ListenAndServeTLS("127.0.0.1:0", "missing.pem", "missing.key",
+		func(net.Conn) {})
+	if err == nil {
+		t.Error("expected an error for missing certificate files")
+	}
+}
@@ -0,0 +1,196 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrUpdateConflict is returned by ScreenSession.Update() when the update
+// would add or replace a writable field on the screen.
+var ErrUpdateConflict = errors.New("go3270: update would alter input fields")
+
+// ScreenSession wraps a connection to a 3270 client and remembers what was
+// last sent to it, so that responses can be compared against the screen the
+// user was looking at. Create a ScreenSession with NewScreenSession().
+//
+// A ScreenSession is safe for one goroutine to block in ShowScreen() waiting
+// for the user while other goroutines send display updates with Update().
+type ScreenSession struct {
+	conn Transport
+
+	// mu serializes writes to conn and protects fm, lastSent, transient,
+	// and size. It is not held while waiting for the client's response.
+	mu sync.Mutex
+
+	// fm is the fieldmap of the most recently sent screen. It is the
+	// authoritative record of where the input fields are that the pending
+	// read is expecting.
+	fm fieldmap
+
+	// lastSent holds the value of every named field in the most recently
+	// sent screen.
+	lastSent map[string]string
+
+	// transient holds the messages for Transient fields set with
+	// SetTransient() that have not been displayed yet.
+	transient map[string]string
+
+	// size is the screen size the most recent screen was sent with, which
+	// Update() uses to place its fields.
+	size screenSize
+}
+
+// NewScreenSession creates a ScreenSession for the connection conn. The
+// telnet options should already be negotiated on the connection.
+func NewScreenSession(conn Transport) *ScreenSession {
+	return &ScreenSession{conn: conn, size: defaultSize}
+}
+
+// ShowScreen behaves like the package-level ShowScreen() function, but
+// additionally populates Response.Changed to indicate which field values
+// the user altered compared to what was sent.
+func (s *ScreenSession) ShowScreen(screen Screen, values map[string]string,
+	crow, ccol int) (Response, error) {
+
+	return s.ShowScreenOpts(screen, values,
+		ScreenOpts{CursorRow: crow, CursorCol: ccol})
+}
+
+// ShowScreenOpts is ShowScreen() with the display options in opts, as for
+// the package-level ShowScreenOpts() function. The screen size set by
+// opts.Rows and opts.Cols is also used by later calls to Update().
+func (s *ScreenSession) ShowScreenOpts(screen Screen,
+	values map[string]string, opts ScreenOpts) (Response, error) {
+
+	screen = resolveRoundTrip(resolveFallbacks(screen, opts), values)
+
+	// Nothing is recorded unless the client receives the screen
+	s.mu.Lock()
+	values, shown := s.withTransient(screen, values)
+	lastSent := sentValues(screen, values)
+	fm, err := writeScreen(screen, values, opts, s.conn)
+	if err != nil {
+		s.mu.Unlock()
+		return Response{}, err
+	}
+	for _, name := range shown {
+		delete(s.transient, name)
+	}
+	s.lastSent = lastSent
+	s.fm = fm
+	s.size = opts.size()
+	s.mu.Unlock()
+
+	// Replies to the client's option negotiation are written under mu
+	rc := &readConn{Transport: s.conn, prefix: opts.LogPrefix,
+		conn: s.conn, mu: &s.mu}
+	resp, err := awaitResponse(context.Background(), screen, fm, rc,
+		opts)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Changed = make(map[string]bool)
+	for name, value := range resp.Values {
+		resp.Changed[name] = value != lastSent[name]
+	}
+
+	return resp, nil
+}
+
+// SetTransient sets a one-shot message, such as an error or confirmation,
+// for the Transient field with the given name. The message is displayed by
+// the next ShowScreen() whose screen includes the field, and not on the
+// screens after that. Setting another message for the field before then
+// replaces the first.
+func (s *ScreenSession) SetTransient(name, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transient == nil {
+		s.transient = make(map[string]string)
+	}
+	s.transient[name] = message
+}
+
+// withTransient returns values with the pending messages for the Transient
+// fields in screen added, and the names of those fields, whose messages are
+// to be forgotten once the screen is displayed. values itself is not
+// modified. s.mu must be held.
+func (s *ScreenSession) withTransient(screen Screen,
+	values map[string]string) (map[string]string, []string) {
+
+	var result map[string]string
+	var shown []string
+	for _, fld := range screen {
+		message, ok := s.transient[fld.Name]
+		if !fld.Transient || fld.Name == "" || !ok {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string, len(values)+1)
+			for name, value := range values {
+				result[name] = value
+			}
+		}
+		result[fld.Name] = message
+		shown = append(shown, fld.Name)
+	}
+	if result == nil {
+		return values, nil
+	}
+	return result, shown
+}
+
+// Update writes the fields in screen to the client without erasing the
+// screen, moving the cursor, or waiting for a response. It is intended for
+// refreshing display-only fields (a clock or status message, for example)
+// while another goroutine is blocked in ShowScreen() waiting for the user.
+//
+// Because the pending ShowScreen() read depends on the positions of the
+// input fields it sent, Update returns ErrUpdateConflict without writing
+// anything if screen contains a writable field or a field positioned on the
+// attribute of an existing input field.
+func (s *ScreenSession) Update(screen Screen, values map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, fld := range screen {
+		if fld.Write {
+			return ErrUpdateConflict
+		}
+		// fieldmap keys are the address after the field attribute
+		if _, ok := s.fm[s.size.addr(fld.Row, fld.Col)+1]; ok {
+			return ErrUpdateConflict
+		}
+	}
+
+	return writeUpdate(screen, values, wccUpdate, s.size, s.conn)
+}
+
+// sentValues returns the value each named field in the screen will have when
+// sent with the override values map. Values for fields that will have their
+// responses trimmed by ShowScreen() are trimmed here as well so they compare
+// equal to unmodified values returned by the client.
+func sentValues(screen Screen, values map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, fld := range screen {
+		if fld.Name == "" {
+			continue
+		}
+		content := fld.Content
+		if val, ok := values[fld.Name]; ok {
+			content = val
+		}
+		if !fld.KeepSpaces {
+			content = strings.TrimSpace(content)
+		}
+		result[fld.Name] = content
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+)
+
+// StatusLine is a full-width, display-only line reserved for status
+// messages, conventionally the last row of the screen. Include the line's
+// field in your screens with Field(), then change the message at any time
+// with Update() without re-sending the rest of the screen.
+type StatusLine struct {
+	// Row is the row, 0-23 on the default 24x80 screen, that the status
+	// line occupies. The field attribute is placed in column 0, so the
+	// message may use columns 1-79.
+	Row int
+
+	// Rows and Cols are the size of the client's screen, when it is set
+	// with ScreenOpts.Rows and ScreenOpts.Cols; they are used only when both
+	// are non-zero. The message may use every column after column 0.
+	Rows int
+	Cols int
+
+	// Color and Intense are the display attributes for the message.
+	Color   Color
+	Intense bool
+}
+
+// Field returns the field for the status line displaying text. Text longer
+// than the line (79 characters on an 80 column screen) is truncated, without
+// splitting a character, and shorter text is padded with spaces so that the
+// new message completely replaces any previous one.
+func (s StatusLine) Field(text string) Field {
+	width := s.width()
+	text = truncateContent(text, width)
+	return Field{
+		Row:     s.Row,
+		Col:     0,
+		Content: text + strings.Repeat(" ", width-len(text)),
+		Color:   s.Color,
+		Intense: s.Intense,
+	}
+}
+
+// width returns the number of positions available for the message.
+func (s StatusLine) width() int {
+	return s.size().cols - 1
+}
+
+// size returns the size of the screen the status line is on.
+func (s StatusLine) size() screenSize {
+	return ScreenOpts{Rows: s.Rows, Cols: s.Cols}.size()
+}
+
+// Update replaces the status line message on the client's screen with text.
+// The rest of the screen, the cursor position, and any input the user has
+// typed are left alone, so Update may be called while another goroutine is
+// waiting for the user to submit the screen. If you are using a
+// ScreenSession, use ScreenSession.Update() with the status line's Field()
+// instead so the writes are serialized with the session's screens.
+func (s StatusLine) Update(conn Transport, text string) error {
+	return writeUpdate(Screen{s.Field(text)}, nil, wccUpdate, s.size(), conn)
+}
@@ -0,0 +1,32 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStatusLineField(t *testing.T) {
+	fld := StatusLine{Row: 23}.Field("Ready")
+	if len(fld.Content) != 79 || !strings.HasPrefix(fld.Content, "Ready ") {
+		t.Errorf("expected Ready padded to 79 positions, got %q",
+			fld.Content)
+	}
+
+	// Truncation never splits a multi-byte character
+	fld = StatusLine{Row: 23}.Field(strings.Repeat("x", 78) + "é")
+	if !utf8.ValidString(fld.Content) || len(fld.Content) != 79 {
+		t.Errorf("expected valid UTF-8 in 79 positions, got %q",
+			fld.Content)
+	}
+
+	fld = StatusLine{Row: 26, Rows: 27, Cols: 132}.Field(strings.Repeat("y", 200))
+	if len(fld.Content) != 131 {
+		t.Errorf("expected 131 positions on a 132 column screen, got %d",
+			len(fld.Content))
+	}
+}
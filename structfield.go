@@ -0,0 +1,98 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrStructuredFieldTooLong is returned when a structured field payload is
+// too long to be represented in the 2-byte structured field length.
+var ErrStructuredFieldTooLong = errors.New(
+	"go3270: structured field too long")
+
+// WriteStructuredField sends a single structured field to the client with the
+// Write Structured Field command. The structured field's length is computed
+// and prefixed, followed by the structured field ID, sfid, and the payload.
+// (For structured fields with 2-byte IDs, include the second byte of the ID
+// at the beginning of the payload.) Telnet IAC bytes are escaped.
+//
+// This is a low-level building block for structured-field features the
+// library doesn't otherwise support, such as Outbound 3270DS; the caller is
+// responsible for the content of the payload and for reading any response.
+func WriteStructuredField(conn Transport, sfid byte, payload []byte) error {
+	// The length includes the 2 length bytes and the ID byte
+	length := len(payload) + 3
+	if length > 0xffff {
+		return ErrStructuredFieldTooLong
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(0xf3) // Write Structured Field
+	b.WriteByte(byte(length >> 8))
+	b.WriteByte(byte(length))
+	b.WriteByte(sfid)
+	b.Write(payload)
+
+	datastream := append(telnetEscape(b.Bytes()), iac, eor)
+	debugf("sending structured field: %x\n", datastream)
+	return writeAll(conn, datastream)
+}
+
+// ErrInvalidStructuredField is returned by ParseStructuredFields() when the
+// data is not a valid sequence of structured fields.
+var ErrInvalidStructuredField = errors.New(
+	"go3270: invalid structured field data")
+
+// StructuredField is a single structured field received from the client.
+type StructuredField struct {
+	// ID is the structured field ID, e.g. 0x81 for a Query Reply.
+	ID byte
+
+	// QCode is the query reply type (the first byte following the ID) when
+	// ID is 0x81 (Query Reply), and 0 otherwise.
+	QCode byte
+
+	// Data is the content of the structured field following the ID. For
+	// query replies, this includes the QCode byte.
+	Data []byte
+}
+
+// ParseStructuredFields splits inbound structured field data, such as the
+// data following the 0x88 (structured field) AID in a client's reply to a
+// Read Partition Query, into its individual length-prefixed structured
+// fields. Doubled telnet IAC bytes are un-escaped, and parsing stops at a
+// telnet IAC EOR if one is present. ErrInvalidStructuredField is returned if
+// a structured field's length is invalid or extends beyond the data.
+func ParseStructuredFields(data []byte) ([]StructuredField, error) {
+	data = telnetUnescape(data)
+
+	var fields []StructuredField
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return fields, ErrInvalidStructuredField
+		}
+
+		// A length of 0 means the structured field extends to the end of
+		// the data.
+		length := int(data[0])<<8 | int(data[1])
+		if length == 0 {
+			length = len(data)
+		}
+		if length < 3 || length > len(data) {
+			return fields, ErrInvalidStructuredField
+		}
+
+		sf := StructuredField{ID: data[2], Data: data[3:length]}
+		if sf.ID == 0x81 && len(sf.Data) > 0 {
+			sf.QCode = sf.Data[0]
+		}
+		fields = append(fields, sf)
+		data = data[length:]
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,79 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseStructuredFields(t *testing.T) {
+	data := []byte{
+		// Query Reply (Summary) listing 0x80, 0x81, 0x86
+		0x00, 0x07, 0x81, 0x80, 0x80, 0x81, 0x86,
+		// Query Reply (Color) containing an escaped 0xff
+		0x00, 0x06, 0x81, 0x86, 0xff, 0xff, 0x00,
+		// End of record
+		0xff, 0xef,
+	}
+
+	fields, err := ParseStructuredFields(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 structured fields, got %d", len(fields))
+	}
+	if fields[0].ID != 0x81 || fields[0].QCode != 0x80 ||
+		!bytes.Equal(fields[0].Data, []byte{0x80, 0x80, 0x81, 0x86}) {
+		t.Errorf("unexpected first field: %+v", fields[0])
+	}
+	if fields[1].QCode != 0x86 ||
+		!bytes.Equal(fields[1].Data, []byte{0x86, 0xff, 0x00}) {
+		t.Errorf("unexpected second field: %+v", fields[1])
+	}
+
+	if _, err := ParseStructuredFields([]byte{0x00, 0x09, 0x81,
+		0x80}); err != ErrInvalidStructuredField {
+		t.Errorf("expected ErrInvalidStructuredField for truncated data, "+
+			"got %v", err)
+	}
+}
+
+func TestWriteStructuredField(t *testing.T) {
+	conn := &recordingTransport{}
+	err := WriteStructuredField(conn, 0x40, []byte{0x01, 0xff, 0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// WSF, a length of 6 including itself and the ID, the ID, then the
+	// payload with its 0xff doubled
+	expected := []byte{0xf3, 0x00, 0x06, 0x40, 0x01, 0xff, 0xff, 0x02,
+		iac, eor}
+	if !bytes.Equal(conn.written.Bytes(), expected) {
+		t.Errorf("expected %x, got %x", expected, conn.written.Bytes())
+	}
+
+	// A length byte of 0xff is escaped too
+	conn.written.Reset()
+	if err = WriteStructuredField(conn, 0x40, make([]byte, 252)); err != nil {
+		t.Fatal(err)
+	}
+	prefix := []byte{0xf3, 0x00, 0xff, 0xff, 0x40, 0x00}
+	if written := conn.written.Bytes(); !bytes.HasPrefix(written, prefix) ||
+		len(written) != 1+3+252+3 {
+		t.Errorf("expected %x... of %d bytes, got %x", prefix, 1+3+252+3,
+			written)
+	}
+
+	conn.written.Reset()
+	err = WriteStructuredField(conn, 0x40, make([]byte, 0xffff-2))
+	if err != ErrStructuredFieldTooLong {
+		t.Errorf("expected ErrStructuredFieldTooLong, got %v", err)
+	}
+	if conn.written.Len() != 0 {
+		t.Error("expected nothing to be written")
+	}
+}
@@ -0,0 +1,280 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotStruct is returned by ScreenFromStruct() and PopulateStruct() when
+// they are not given a struct (or, for PopulateStruct, a pointer to one).
+var ErrNotStruct = errors.New("go3270: value is not a struct")
+
+// structField is the parsed form of a go3270 struct tag.
+type structField struct {
+	index    int    // index of the field in the struct
+	name     string // go3270 field name
+	row, col int
+	label    string
+	length   int
+	hidden   bool
+	numeric  bool
+	validate Validator
+	format   string // time layout for time.Time fields
+}
+
+// ScreenFromStruct builds a data-entry screen from the exported fields of
+// the struct v (or pointer to a struct) that have a go3270 struct tag. The
+// tag is a comma-separated list of key=value settings and flags:
+//
+//   - row and col are the position of the label, or of the input field if
+//     there is no label.
+//   - label is text displayed before the input field.
+//   - length is the width of the input field (default 10). A stop field is
+//     placed after it.
+//   - name is the field name used in the screen and the Response (default
+//     the struct field's name).
+//   - validate is "nonblank" or "integer", adding a rule with NonBlank or
+//     IsInteger as the Validator.
+//   - hidden and numeric set the corresponding Field options.
+//   - format is the time layout for time.Time fields (see Unmarshal()).
+//
+// For example:
+//
+//	type Login struct {
+//	    Username string `go3270:"row=4,col=0,label=Username,validate=nonblank"`
+//	    Password string `go3270:"row=5,col=0,label=Password,hidden"`
+//	}
+//
+// The current values of the struct fields become the initial content of the
+// input fields; zero values are displayed as blank fields. The returned
+// Rules contain an entry for each field with a validate setting.
+func ScreenFromStruct(v interface{}) (Screen, Rules, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, ErrNotStruct
+	}
+
+	fields, err := parseStructTags(rv.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var screen Screen
+	rules := make(Rules)
+	for _, sf := range fields {
+		col := sf.col
+		if sf.label != "" {
+			screen = append(screen, Field{Row: sf.row, Col: col,
+				Content: sf.label})
+			col += len(sf.label) + 1
+		}
+
+		var content string
+		if value := rv.Field(sf.index); !value.IsZero() {
+			content = fmt.Sprint(value.Interface())
+			if t, ok := value.Interface().(time.Time); ok {
+				content = t.Format(sf.timeFormat())
+			}
+		}
+		highlight := Underscore
+		if sf.hidden {
+			highlight = DefaultHighlight
+		}
+		screen = append(screen, Field{
+			Row:          sf.row,
+			Col:          col,
+			Name:         sf.name,
+			Content:      content,
+			Write:        true,
+			Hidden:       sf.hidden,
+			NumericOnly:  sf.numeric,
+			Highlighting: highlight,
+		})
+		screen = append(screen, Field{Row: sf.row,
+			Col: col + sf.length + 1, Autoskip: true})
+
+		if sf.validate != nil {
+			rules[sf.name] = FieldRules{Validator: sf.validate}
+		}
+	}
+
+	return screen, rules, nil
+}
+
+// PopulateStruct sets the fields of the struct pointed to by v from the
+// values in resp, using the field names from the go3270 struct tags as
+// ScreenFromStruct() does. It is equivalent to resp.Unmarshal(v).
+func PopulateStruct(resp Response, v interface{}) error {
+	return resp.Unmarshal(v)
+}
+
+// Unmarshal sets the fields of the struct pointed to by v from r.Values,
+// using the field names from the go3270 struct tags as ScreenFromStruct()
+// does. Fields that don't appear in r.Values are left unchanged. Values are
+// converted to the type of the struct field:
+//
+//   - string fields are set as-is.
+//   - int and uint fields of any size are parsed as base 10 integers.
+//   - bool fields accept the values strconv.ParseBool() does, as well as Y,
+//     YES, N, and NO in any case.
+//   - time.Time fields are parsed with the layout given by the tag's format
+//     setting, by default "2006-01-02".
+//
+// A blank value sets a non-string field to its zero value. An error is
+// returned for a value that can't be converted, or a struct field of any
+// other type.
+func (r Response) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+	rv = rv.Elem()
+
+	fields, err := parseStructTags(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, sf := range fields {
+		value, ok := r.Values[sf.name]
+		if !ok {
+			continue
+		}
+		if err := setStructField(rv.Field(sf.index), value, sf); err != nil {
+			return fmt.Errorf("go3270: field %s: %v",
+				rv.Type().Field(sf.index).Name, err)
+		}
+	}
+	return nil
+}
+
+// timeFormat returns the layout for formatting and parsing time.Time
+// fields.
+func (sf structField) timeFormat() string {
+	if sf.format == "" {
+		return "2006-01-02"
+	}
+	return sf.format
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setStructField converts value to the type of target and sets it.
+func setStructField(target reflect.Value, value string, sf structField) error {
+	if target.Kind() == reflect.String {
+		target.SetString(value)
+		return nil
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(n)
+	case reflect.Bool:
+		switch strings.ToUpper(value) {
+		case "Y", "YES":
+			target.SetBool(true)
+		case "N", "NO":
+			target.SetBool(false)
+		default:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			target.SetBool(b)
+		}
+	default:
+		if target.Type() != timeType {
+			return fmt.Errorf("unsupported type %s", target.Type())
+		}
+		t, err := time.Parse(sf.timeFormat(), value)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(t))
+	}
+	return nil
+}
+
+// parseStructTags returns the parsed go3270 tags of the exported fields in
+// the struct type t that have one.
+func parseStructTags(t reflect.Type) ([]structField, error) {
+	var result []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("go3270")
+		if !ok || f.PkgPath != "" {
+			continue
+		}
+
+		sf := structField{index: i, name: f.Name, length: 10}
+		for _, setting := range strings.Split(tag, ",") {
+			key, value := setting, ""
+			if eq := strings.Index(setting, "="); eq >= 0 {
+				key, value = setting[:eq], setting[eq+1:]
+			}
+
+			var err error
+			switch key {
+			case "row":
+				sf.row, err = strconv.Atoi(value)
+			case "col":
+				sf.col, err = strconv.Atoi(value)
+			case "length":
+				sf.length, err = strconv.Atoi(value)
+			case "label":
+				sf.label = value
+			case "name":
+				sf.name = value
+			case "format":
+				sf.format = value
+			case "hidden":
+				sf.hidden = true
+			case "numeric":
+				sf.numeric = true
+			case "validate":
+				switch value {
+				case "nonblank":
+					sf.validate = NonBlank
+				case "integer":
+					sf.validate = IsInteger
+				default:
+					err = fmt.Errorf("unknown validator %q", value)
+				}
+			default:
+				err = fmt.Errorf("unknown setting %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("go3270: bad tag on field %s: %v",
+					f.Name, err)
+			}
+		}
+		result = append(result, sf)
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testLogin struct {
+	Username string `go3270:"row=4,col=0,label=Username,length=8,validate=nonblank"`
+	Password string `go3270:"row=5,col=2,name=pw,hidden"`
+	internal string `go3270:"row=1,col=1"`
+}
+
+func TestScreenFromStruct(t *testing.T) {
+	screen, rules, err := ScreenFromStruct(testLogin{Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Screen{
+		{Row: 4, Col: 0, Content: "Username"},
+		{Row: 4, Col: 9, Name: "Username", Content: "bob", Write: true,
+			Highlighting: Underscore},
+		{Row: 4, Col: 18, Autoskip: true},
+		{Row: 5, Col: 2, Name: "pw", Write: true, Hidden: true},
+		{Row: 5, Col: 13, Autoskip: true},
+	}
+	if len(screen) != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), len(screen))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(screen[i], expected[i]) {
+			t.Errorf("field %d: expected %+v, got %+v", i, expected[i],
+				screen[i])
+		}
+	}
+	if len(rules) != 1 || rules["Username"].Validator == nil {
+		t.Errorf("expected a validator for Username only, got %v", rules)
+	}
+
+	var login testLogin
+	err = PopulateStruct(Response{Values: map[string]string{
+		"Username": "alice", "pw": "secret"}}, &login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Username != "alice" || login.Password != "secret" {
+		t.Errorf("unexpected struct after populate: %+v", login)
+	}
+}
+
+func TestResponseUnmarshal(t *testing.T) {
+	var rec struct {
+		Name   string    `go3270:"row=1,col=0"`
+		Age    int       `go3270:"row=2,col=0"`
+		Member bool      `go3270:"row=3,col=0"`
+		Joined time.Time `go3270:"row=4,col=0,format=01/02/2006"`
+		Count  uint8     `go3270:"row=5,col=0"`
+	}
+	rec.Count = 7
+
+	resp := Response{Values: map[string]string{
+		"Name": "Ann", "Age": "42", "Member": "y", "Joined": "03/15/2020",
+		"Count": ""}}
+	if err := resp.Unmarshal(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "Ann" || rec.Age != 42 || !rec.Member || rec.Count != 0 ||
+		!rec.Joined.Equal(time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected struct after unmarshal: %+v", rec)
+	}
+
+	resp.Values["Age"] = "forty"
+	if err := resp.Unmarshal(&rec); err == nil {
+		t.Error("expected an error for a non-integer value")
+	}
+}
@@ -5,7 +5,10 @@
 package go3270
 
 import (
-	"net"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 )
 
@@ -19,50 +22,227 @@ const (
 	do           = 253 // fd
 	dont         = 254 // fe
 	iac          = 255 // ff
+	timingMark   = 6   // 06
 	terminalType = 24  // 18
 	eoroption    = 25  // 19
 	eor          = 239 // f1
 )
 
-// NegotiateTelnet will naively (e.g. not checking client responses) negotiate
-// the options necessary for tn3270 on a new telnet connection, conn.
-func NegotiateTelnet(conn net.Conn) error {
-	conn.Write([]byte{iac, do, terminalType})
-	conn.Write([]byte{iac, sb, terminalType, send, iac, se})
-	conn.Write([]byte{iac, do, eoroption})
-	conn.Write([]byte{iac, do, binary})
-	conn.Write([]byte{iac, will, eoroption, iac, will, binary})
-	flushConnection(conn, time.Second*5)
+// ErrOptionRefused is the error wrapped by the NegotiationError returned
+// by NegotiateTelnet() when the client refuses one of the telnet options
+// tn3270 requires.
+var ErrOptionRefused = errors.New("go3270: client refused a telnet option")
+
+// NegotiationError is returned by NegotiateTelnet() and UnNegotiateTelnet()
+// when the negotiation fails. It records the step of the negotiation that
+// failed and the bytes received from the client before the failure, and
+// wraps the underlying error, so errors.Is() and errors.As() may be used to
+// inspect the cause.
+type NegotiationError struct {
+	// Stage describes the negotiation step that failed, e.g. "DO BINARY".
+	Stage string
+
+	// Seen holds the bytes read from the client during the negotiation
+	// before the failure occurred.
+	Seen []byte
+
+	// Verb and Option are the client's refusal, WONT or DONT, and the
+	// option it refused, when Err is ErrOptionRefused. They are 0
+	// otherwise.
+	Verb   byte
+	Option byte
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *NegotiationError) Error() string {
+	return fmt.Sprintf("telnet negotiation failed at %s (received % x): %v",
+		e.Stage, e.Seen, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NegotiationError) Unwrap() error {
+	return e.Err
+}
+
+// negotiationStep is one telnet command sent during option negotiation.
+type negotiationStep struct {
+	stage   string
+	command []byte
+}
+
+// NegotiateTelnet negotiates the options necessary for tn3270 on a new
+// telnet connection, conn. If a step of the negotiation fails, a
+// *NegotiationError is returned. The client's responses are only checked
+// for refusals of the options: if the client refuses one, the
+// NegotiationError wraps ErrOptionRefused.
+//
+// NegotiateTelnet may be called again on a connection after
+// UnNegotiateTelnet() to return to tn3270 mode, for example after running
+// another protocol over the connection. Anything the client sent before or
+// in response to the negotiation is discarded.
+func NegotiateTelnet(conn Transport) error {
+	forgetOptions(conn)
+	return negotiate(conn, []negotiationStep{
+		{"DO TERMINAL-TYPE", []byte{iac, do, terminalType}},
+		{"TERMINAL-TYPE SEND",
+			[]byte{iac, sb, terminalType, send, iac, se}},
+		{"DO EOR", []byte{iac, do, eoroption}},
+		{"DO BINARY", []byte{iac, do, binary}},
+		{"WILL EOR, WILL BINARY",
+			[]byte{iac, will, eoroption, iac, will, binary}},
+	}, time.Second*5, refusal)
+}
+
+// optionNames are the names of the options NegotiateTelnet() requests.
+var optionNames = map[byte]string{
+	binary:       "BINARY",
+	eoroption:    "EOR",
+	terminalType: "TERMINAL-TYPE",
+}
+
+// refusal returns a NegotiationError if the client's responses to
+// NegotiateTelnet(), seen, refuse one of the options it requested, or nil.
+func refusal(seen []byte) *NegotiationError {
+	requested := negotiatedOptions()
+	for i := 0; i+2 < len(seen); i++ {
+		if seen[i] != iac {
+			continue
+		}
+		verb, opt := seen[i+1], seen[i+2]
+		var stage string
+		switch {
+		case verb == iac:
+			// An escaped 0xff data byte
+			i++
+			continue
+		case verb == wont && requested.remote[opt]:
+			stage = "DO " + optionNames[opt]
+		case verb == dont && requested.local[opt]:
+			stage = "WILL " + optionNames[opt]
+		default:
+			continue
+		}
+		return &NegotiationError{Stage: stage, Seen: seen, Verb: verb,
+			Option: opt, Err: ErrOptionRefused}
+	}
 	return nil
 }
 
 // UnNegotiateTelnet will naively (e.g. not checking client responses) attempt
 // to restore the telnet options state to what it was before NegotiateTelnet()
-// was called.
-func UnNegotiateTelnet(conn net.Conn, timeout time.Duration) error {
-	conn.Write([]byte{iac, wont, eoroption, iac, wont, binary})
-	conn.Write([]byte{iac, dont, binary})
-	conn.Write([]byte{iac, dont, eoroption})
-	conn.Write([]byte{iac, dont, terminalType})
-	flushConnection(conn, timeout)
+// was called. If a step of the negotiation fails, a *NegotiationError is
+// returned.
+func UnNegotiateTelnet(conn Transport, timeout time.Duration) error {
+	forgetOptions(conn)
+	return negotiate(conn, []negotiationStep{
+		{"WONT EOR, WONT BINARY",
+			[]byte{iac, wont, eoroption, iac, wont, binary}},
+		{"DONT BINARY", []byte{iac, dont, binary}},
+		{"DONT EOR", []byte{iac, dont, eoroption}},
+		{"DONT TERMINAL-TYPE", []byte{iac, dont, terminalType}},
+	}, timeout, nil)
+}
+
+// Ping measures the round-trip time to the client using the telnet
+// TIMING-MARK option: it sends DO TIMING-MARK and waits, up to timeout, for
+// the client's WILL or WONT reply, which every telnet client sends as soon
+// as it receives the request. This measures the network and client
+// latency, unlike timing a screen's response, which includes the time the
+// user takes to respond.
+//
+// Ping reads from conn directly and discards any other data received before
+// the reply, so it must not be called while a screen is waiting for a
+// response. Call it after reading a response and before sending the next
+// screen, when the client's keyboard is locked and the user cannot send
+// anything.
+func Ping(conn Transport, timeout time.Duration) (time.Duration, error) {
+	defer conn.SetReadDeadline(time.Time{})
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	if err := writeAll(conn, []byte{iac, do, timingMark}); err != nil {
+		return 0, err
+	}
+
+	var last [3]byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return 0, err
+		}
+		last[0], last[1], last[2] = last[1], last[2], buf[0]
+		if last[0] == iac && (last[1] == will || last[1] == wont) &&
+			last[2] == timingMark {
+			rtt := time.Since(start)
+			debugf("timing mark reply after %v\n", rtt)
+			return rtt, nil
+		}
+	}
+}
+
+// negotiate sends each of the steps to the client, then discards the
+// client's responses, waiting up to timeout for the first one. If check is
+// not nil, it is given the responses and returns an error if they refuse
+// the negotiation.
+func negotiate(conn Transport, steps []negotiationStep,
+	timeout time.Duration, check func(seen []byte) *NegotiationError) error {
+
+	for _, step := range steps {
+		if err := writeAll(conn, step.command); err != nil {
+			return &NegotiationError{Stage: step.stage, Err: err}
+		}
+	}
+
+	seen, err := flushConnection(conn, timeout)
+	if check != nil {
+		if nerr := check(seen); nerr != nil {
+			return nerr
+		}
+	}
+	if err != nil {
+		return &NegotiationError{Stage: "reading client responses",
+			Seen: seen, Err: err}
+	}
+
 	return nil
 }
 
+// FlushInput discards any input from the client that is waiting to be read,
+// such as keys the user pressed while the previous screen was being
+// processed. Call it before showing a screen where type-ahead would be
+// dangerous, such as a confirmation of a destructive action, so that a
+// stale response is not taken as the answer to the new screen.
+//
+// FlushInput waits up to timeout for input to arrive, and keeps reading as
+// long as more arrives within half a second, so it always takes at least
+// timeout to return. A short timeout (tens of milliseconds) keeps screens
+// responsive but may miss input still in transit on slow links; a longer
+// timeout catches more but delays every screen it precedes.
+func FlushInput(conn Transport, timeout time.Duration) error {
+	_, err := flushConnection(conn, timeout)
+	return err
+}
+
 // flushConnection discards all bytes that it can read from conn, allowing up
-// to the duration timeout for the first byte to be read.
-func flushConnection(conn net.Conn, timeout time.Duration) error {
+// to the duration timeout for the first byte to be read. The discarded bytes
+// are returned.
+func flushConnection(conn Transport, timeout time.Duration) ([]byte, error) {
 	defer conn.SetReadDeadline(time.Time{})
+	var seen []byte
 	buffer := make([]byte, 1024)
 	for {
 		conn.SetReadDeadline(time.Now().Add(timeout))
 		n, err := conn.Read(buffer)
-		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+		seen = append(seen, buffer[:n]...)
+		if isTimeout(err) {
 			debugf("nothing to flush\n")
-			return nil
+			return seen, nil
 		}
 		if err != nil {
 			debugf("error while flushing: %v\n", err)
-			return err
+			return seen, err
 		}
 		debugf("%d bytes read while flushing connection\n", n)
 		// for follow-up reads, reduce the timeout
@@ -70,6 +250,231 @@ func flushConnection(conn net.Conn, timeout time.Duration) error {
 	}
 }
 
+// telnetEscape returns a copy of data with each telnet IAC (0xff) byte
+// doubled, so that it is interpreted by the client as data rather than as
+// the start of a telnet command.
+func telnetEscape(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for _, b := range data {
+		result = append(result, b)
+		if b == iac {
+			result = append(result, iac)
+		}
+	}
+	return result
+}
+
+// telnetUnescape returns a copy of data with doubled telnet IAC bytes
+// replaced by a single 0xff byte. If data contains a telnet IAC EOR, the
+// result ends before it.
+func telnetUnescape(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == iac && i+1 < len(data) {
+			i++
+			if data[i] == eor {
+				break
+			}
+		}
+		result = append(result, data[i])
+	}
+	return result
+}
+
+// telnetOptions is the state of the telnet options on a connection: the
+// options each side has agreed to perform. It is used to answer option
+// negotiation from the client during a session only when the request would
+// change an option's state; RFC 854 forbids acknowledging a request for the
+// state an option is already in, and two peers that do so can loop.
+type telnetOptions struct {
+	local  map[byte]bool // options we perform (we sent WILL)
+	remote map[byte]bool // options the client performs (we sent DO)
+}
+
+// negotiatedOptions returns the state NegotiateTelnet() leaves a connection
+// in.
+func negotiatedOptions() *telnetOptions {
+	return &telnetOptions{
+		local: map[byte]bool{binary: true, eoroption: true},
+		remote: map[byte]bool{binary: true, eoroption: true,
+			terminalType: true},
+	}
+}
+
+// reply updates the option state for the negotiation command verb for the
+// option opt received from the client, and returns the reply to send, or
+// nil if none is needed. We agree to the options tn3270 requires and refuse
+// all others, and agree to the client disabling any option, as a telnet
+// peer must.
+func (o *telnetOptions) reply(verb, opt byte) []byte {
+	switch verb {
+	case do:
+		if o.local[opt] {
+			return nil
+		}
+		if opt == binary || opt == eoroption {
+			o.local[opt] = true
+			return []byte{iac, will, opt}
+		}
+		return []byte{iac, wont, opt}
+	case dont:
+		if !o.local[opt] {
+			return nil
+		}
+		o.local[opt] = false
+		return []byte{iac, wont, opt}
+	case will:
+		if o.remote[opt] {
+			return nil
+		}
+		if opt == binary || opt == eoroption || opt == terminalType {
+			o.remote[opt] = true
+			return []byte{iac, do, opt}
+		}
+		return []byte{iac, dont, opt}
+	case wont:
+		if !o.remote[opt] {
+			return nil
+		}
+		o.remote[opt] = false
+		return []byte{iac, dont, opt}
+	}
+	return nil
+}
+
+// connOptions holds the telnet option state of each connection whose
+// client has changed its options from the state NegotiateTelnet() leaves
+// them in, so the state carries over from one screen's read to the next.
+// Connections in the negotiated state have no entry, so nothing is kept for
+// a connection that closes in that state, and NegotiateTelnet() and
+// UnNegotiateTelnet() remove a connection's entry. Transports that are not
+// comparable can't be map keys and are always taken to be in the negotiated
+// state.
+var connOptions = struct {
+	sync.Mutex
+	m map[Transport]*telnetOptions
+}{m: make(map[Transport]*telnetOptions)}
+
+// optionsFor returns the telnet option state of conn. connOptions must be
+// locked.
+func optionsFor(conn Transport) *telnetOptions {
+	if options, ok := connOptions.m[conn]; ok {
+		return options
+	}
+	return negotiatedOptions()
+}
+
+// storeOptions records options as the telnet option state of conn.
+// connOptions must be locked.
+func storeOptions(conn Transport, options *telnetOptions) {
+	if !keyable(conn) {
+		return
+	}
+	if options.equal(negotiatedOptions()) {
+		delete(connOptions.m, conn)
+		return
+	}
+	connOptions.m[conn] = options
+}
+
+// forgetOptions removes the recorded telnet option state of conn, after
+// the options have been negotiated again.
+func forgetOptions(conn Transport) {
+	if !keyable(conn) {
+		return
+	}
+	connOptions.Lock()
+	delete(connOptions.m, conn)
+	connOptions.Unlock()
+}
+
+// keyable returns true if conn can be used as a map key.
+func keyable(conn Transport) bool {
+	return conn != nil && reflect.TypeOf(conn).Comparable()
+}
+
+// equal returns true if o and p have the same options enabled.
+func (o *telnetOptions) equal(p *telnetOptions) bool {
+	return sameOptions(o.local, p.local) && sameOptions(o.remote, p.remote)
+}
+
+// sameOptions returns true if the same options are enabled in a and b.
+func sameOptions(a, b map[byte]bool) bool {
+	for opt, on := range a {
+		if b[opt] != on {
+			return false
+		}
+	}
+	for opt, on := range b {
+		if a[opt] != on {
+			return false
+		}
+	}
+	return true
+}
+
+// readConn is the Transport a response is read through. It carries the
+// ScreenOpts.LogPrefix for the debug output about the reads, and the client
+// connection whose telnet option state replyToOption() uses.
+type readConn struct {
+	Transport
+	prefix string
+
+	// conn is the client connection. It is usually the embedded Transport,
+	// but may differ when that only reads data already received from the
+	// connection (see PendingScreen).
+	conn Transport
+
+	// mu, if not nil, is held while replying to option negotiation, to
+	// serialize the reply with other writes to the connection (see
+	// ScreenSession).
+	mu *sync.Mutex
+}
+
+// newReadConn returns conn wrapped to read a response, with the log prefix.
+func newReadConn(conn Transport, prefix string) *readConn {
+	return &readConn{Transport: conn, prefix: prefix, conn: conn}
+}
+
+// replyToOption responds to a telnet option negotiation command received
+// from the client in the middle of a session, if it changes the state of
+// the option, which is kept for the connection across reads (see
+// connOptions). Clients may re-assert options at any time (after a resize,
+// for example), which needs no reply.
+func replyToOption(c Transport, verb, opt byte) error {
+	conn := c
+	rc, _ := c.(*readConn)
+	if rc != nil {
+		conn = rc.conn
+	}
+
+	connOptions.Lock()
+	options := optionsFor(conn)
+	reply := options.reply(verb, opt)
+	storeOptions(conn, options)
+	connOptions.Unlock()
+
+	if reply == nil {
+		debugConnf(c, "option negotiation %02x %02x needs no reply\n", verb,
+			opt)
+		return nil
+	}
+
+	debugConnf(c, "replying to telnet option negotiation: %x\n", reply)
+	if rc != nil && rc.mu != nil {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+	}
+	return writeAll(c, reply)
+}
+
+// isTimeout returns true if err is a timeout error, such as a net.Error
+// returned when a read deadline passes.
+func isTimeout(err error) bool {
+	t, ok := err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
 // telnetRead returns the next byte of data from the connection c, but
 // filters out all telnet commands. If passEOR is true, then telnetRead will
 // return upon encountering the telnet End of Record command, setting isEor to
@@ -78,15 +483,17 @@ func flushConnection(conn net.Conn, timeout time.Duration) error {
 // value read from the connection; when value is false, do not use the value
 // in b. (For example, a valid byte AND error can be returned in the same
 // call.)
-func telnetRead(c net.Conn, passEOR bool) (b byte, valid, isEor bool, err error) {
+func telnetRead(c Transport, passEOR bool) (b byte, valid, isEor bool, err error) {
 	const (
 		normal = iota
 		command
+		option
 		subneg
 	)
 
 	buf := make([]byte, 1)
 	state := normal
+	var verb byte // the option negotiation command when in option state
 
 	for {
 		bn, berr := c.Read(buf)
@@ -108,32 +515,45 @@ func telnetRead(c net.Conn, passEOR bool) (b byte, valid, isEor bool, err error)
 		case normal:
 			if buf[0] == iac {
 				state = command
-				debugf("entering telnet command state\n")
+				debugConnf(c, "entering telnet command state\n")
 			} else {
 				return buf[0], true, false, berr
 			}
 		case command:
 			if buf[0] == 0xff {
-				debugf("leaving telnet command state; was an escaped 0xff\n")
+				debugConnf(c,
+					"leaving telnet command state; was an escaped 0xff\n")
 				return 0xff, true, false, nil
 			} else if buf[0] == sb {
 				state = subneg
-				debugf("entering telnet command subnegotiation state\n")
+				debugConnf(c, "entering telnet command subnegotiation state\n")
 			} else if passEOR && buf[0] == eor {
-				debugf("leaving telnet command state; returning EOR\n")
+				debugConnf(c, "leaving telnet command state; returning EOR\n")
 				return 0, false, true, nil
+			} else if buf[0] >= will && buf[0] <= dont {
+				state = option
+				verb = buf[0]
 			} else {
 				state = normal
-				debugf("leaving telnet command state; command was %02x\n",
+				debugConnf(c,
+					"leaving telnet command state; command was %02x\n",
 					buf[0])
 			}
+		case option:
+			state = normal
+			debugConnf(c, "leaving telnet command state; option negotiation "+
+				"%02x %02x\n", verb, buf[0])
+			if err := replyToOption(c, verb, buf[0]); err != nil {
+				return 0, false, false, err
+			}
 		case subneg:
 			if buf[0] == se {
 				state = normal
-				debugf("leaving telnet command subnegotiation state\n")
+				debugConnf(c, "leaving telnet command subnegotiation state\n")
 			} else {
 				// remain in subnegotiation consuming bytes until we get se
-				debugf("consumed telnet subnegotiation byte: %02x\n", buf[0])
+				debugConnf(c, "consumed telnet subnegotiation byte: %02x\n",
+					buf[0])
 			}
 		}
 	}
@@ -0,0 +1,281 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// recordingTransport is a Transport that reads canned client data and
+// records what is written to it.
+type recordingTransport struct {
+	in      *bytes.Reader
+	written bytes.Buffer
+}
+
+func (t *recordingTransport) Read(b []byte) (int, error) {
+	return t.in.Read(b)
+}
+
+func (t *recordingTransport) Write(b []byte) (int, error) {
+	return t.written.Write(b)
+}
+
+func (t *recordingTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func TestTelnetReadRenegotiation(t *testing.T) {
+	// The client re-asserts DO BINARY and WILL EOR, and asks us to DO ECHO,
+	// before sending a data byte.
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		iac, do, binary, iac, will, eoroption, iac, do, 1, 0x7d})}
+
+	b, valid, _, err := telnetRead(conn, false)
+	if err != nil || !valid || b != 0x7d {
+		t.Fatalf("expected data byte 7d, got %02x (valid=%v, err=%v)",
+			b, valid, err)
+	}
+
+	// Options already in the requested state are not acknowledged
+	expected := []byte{iac, wont, 1}
+	if !bytes.Equal(conn.written.Bytes(), expected) {
+		t.Errorf("expected replies %x, got %x", expected,
+			conn.written.Bytes())
+	}
+}
+
+func TestTelnetReadOptionState(t *testing.T) {
+	// The client disables BINARY twice, then enables it again.
+	in := &recordingTransport{in: bytes.NewReader([]byte{
+		iac, dont, binary, iac, dont, binary, iac, do, binary, 0x7d})}
+	conn := newReadConn(in, "")
+
+	if _, _, _, err := telnetRead(conn, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{iac, wont, binary, iac, will, binary}
+	if !bytes.Equal(in.written.Bytes(), expected) {
+		t.Errorf("expected replies %x, got %x", expected,
+			in.written.Bytes())
+	}
+}
+
+func TestTelnetOptionStateAcrossScreens(t *testing.T) {
+	screen := Screen{{Row: 0, Col: 0, Content: "hi"}}
+
+	// The client disables BINARY while the first screen is displayed...
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		iac, dont, binary, 0x7d, 0x40, 0x40, iac, eor})}
+	if _, err := ShowScreenOpts(screen, nil, conn, ScreenOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.written.Bytes(), []byte{iac, wont, binary}) {
+		t.Errorf("expected WONT BINARY, got %x", conn.written.Bytes())
+	}
+
+	// ...and enables it again during the next one.
+	conn.written.Reset()
+	conn.in = bytes.NewReader([]byte{
+		iac, do, binary, 0x7d, 0x40, 0x40, iac, eor})
+	if _, err := ShowScreenOpts(screen, nil, conn, ScreenOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.written.Bytes(), []byte{iac, will, binary}) {
+		t.Errorf("expected WILL BINARY, got %x", conn.written.Bytes())
+	}
+
+	// Back in the negotiated state, nothing is kept for the connection
+	connOptions.Lock()
+	_, kept := connOptions.m[conn]
+	connOptions.Unlock()
+	if kept {
+		t.Error("expected no option state to be kept")
+	}
+}
+
+// fakeTelnetClient answers the telnet option negotiation sent to conn as an
+// agreeable tn3270 client would. Replies are queued so the client never
+// blocks the server's writes. Data to send is also written through the
+// queue, and received data bytes are discarded.
+func fakeTelnetClient(conn net.Conn) chan<- []byte {
+	out := make(chan []byte, 100)
+	go func() {
+		for b := range out {
+			if _, err := conn.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		var cmd []byte
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+			b := buf[0]
+			if len(cmd) == 0 {
+				if b == iac {
+					cmd = append(cmd, b)
+				}
+				continue
+			}
+			cmd = append(cmd, b)
+			switch {
+			case cmd[1] == sb:
+				if b != se {
+					continue
+				}
+				reply := []byte{iac, sb, terminalType, 0} // IS
+				reply = append(reply, "IBM-3278-2"...)
+				out <- append(reply, iac, se)
+			case len(cmd) < 3 && cmd[1] >= will:
+				continue
+			case cmd[1] == do:
+				out <- []byte{iac, will, b}
+			case cmd[1] == will:
+				out <- []byte{iac, do, b}
+			case cmd[1] == dont:
+				out <- []byte{iac, wont, b}
+			case cmd[1] == wont:
+				out <- []byte{iac, dont, b}
+			}
+			cmd = nil
+		}
+	}()
+
+	return out
+}
+
+func TestRenegotiation(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	out := fakeTelnetClient(client)
+
+	if err := NegotiateTelnet(server); err != nil {
+		t.Fatalf("first negotiation: %v", err)
+	}
+	if err := UnNegotiateTelnet(server, time.Second); err != nil {
+		t.Fatalf("unnegotiation: %v", err)
+	}
+
+	// Something left over from another protocol
+	out <- []byte("stale data")
+
+	if err := NegotiateTelnet(server); err != nil {
+		t.Fatalf("second negotiation: %v", err)
+	}
+
+	// The connection is usable for tn3270 again
+	out <- []byte{0x7d, 0x40, 0xc1, iac, eor}
+	resp, err := ShowScreen(Screen{{Row: 0, Col: 0, Content: "hi"}}, nil,
+		0, 0, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDEnter {
+		t.Errorf("expected Enter, got %s", AIDtoString(resp.AID))
+	}
+}
+
+func TestNegotiationRefused(t *testing.T) {
+	// The client agrees to TERMINAL-TYPE but refuses BINARY
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		iac, will, terminalType, iac, wont, binary})}
+
+	err := NegotiateTelnet(conn)
+	var nerr *NegotiationError
+	if !errors.As(err, &nerr) {
+		t.Fatalf("expected a *NegotiationError, got %v", err)
+	}
+	if nerr.Stage != "DO BINARY" || nerr.Verb != wont ||
+		nerr.Option != binary {
+		t.Errorf("unexpected stage %q, verb %d, option %d", nerr.Stage,
+			nerr.Verb, nerr.Option)
+	}
+	if !errors.Is(err, ErrOptionRefused) {
+		t.Errorf("expected ErrOptionRefused, got %v", err)
+	}
+	if !bytes.Equal(nerr.Seen, []byte{iac, will, terminalType, iac, wont,
+		binary}) {
+		t.Errorf("unexpected seen bytes %x", nerr.Seen)
+	}
+
+	// Refusing to let us send EOR is caught as well; an escaped 0xff data
+	// byte before it is not mistaken for a command.
+	conn = &recordingTransport{in: bytes.NewReader([]byte{
+		iac, iac, dont, binary, iac, dont, eoroption})}
+	err = NegotiateTelnet(conn)
+	if !errors.As(err, &nerr) || nerr.Stage != "WILL EOR" ||
+		nerr.Verb != dont || nerr.Option != eoroption {
+		t.Errorf("expected WILL EOR to be refused, got %v", err)
+	}
+}
+
+func TestNegotiationWriteError(t *testing.T) {
+	conn := &failingTransport{recordingTransport: &recordingTransport{
+		in: bytes.NewReader(nil)}, fail: true}
+
+	err := NegotiateTelnet(conn)
+	var nerr *NegotiationError
+	if !errors.As(err, &nerr) {
+		t.Fatalf("expected a *NegotiationError, got %v", err)
+	}
+	if nerr.Stage != "DO TERMINAL-TYPE" || nerr.Verb != 0 ||
+		nerr.Option != 0 {
+		t.Errorf("unexpected stage %q, verb %d, option %d", nerr.Stage,
+			nerr.Verb, nerr.Option)
+	}
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected the write error to be wrapped, got %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	fakeTelnetClient(client)
+
+	rtt, err := Ping(server, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt <= 0 || rtt >= time.Second {
+		t.Errorf("unexpected round-trip time %v", rtt)
+	}
+}
+
+func TestFlushInput(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go client.Write([]byte{0x7d, 0x40, 0x40, iac, eor})
+
+	if err := FlushInput(server, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stale Enter must not be read as the response to the next screen
+	go client.Write([]byte{0xf3, 0x40, 0x40, iac, eor})
+	resp, err := WaitForKey(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AID != AIDPF3 {
+		t.Errorf("expected PF3, got %s", AIDtoString(resp.AID))
+	}
+}
@@ -0,0 +1,70 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// Transport is the connection to a tn3270 client that go3270 reads from and
+// writes to. A net.Conn satisfies Transport, so normally you will pass the
+// connections you accept from a net.Listener directly to the library. Other
+// implementations allow the 3270 datastream to be carried over something
+// other than a raw TCP connection, such as a WebSocket to a browser-based
+// emulator, or an in-memory pipe in tests.
+//
+// Read and Write must behave as for net.Conn. When a read deadline set by
+// SetReadDeadline passes, Read must return an error with a Timeout() method
+// that returns true, as net.Error does.
+type Transport interface {
+	Read(b []byte) (n int, err error)
+	Write(b []byte) (n int, err error)
+	SetReadDeadline(t time.Time) error
+}
+
+// writeAll writes all of b to conn. Write may return having written fewer
+// bytes than requested without an error on some Transports, and a partial
+// datastream would leave the client out of sync, so writeAll continues
+// writing the remainder until everything is written or an error occurs.
+func writeAll(conn Transport, b []byte) error {
+	for len(b) > 0 {
+		n, err := conn.Write(b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// bufferedConn is a Transport with buffered reads.
+type bufferedConn struct {
+	Transport
+	r *bufio.Reader
+}
+
+// NewBufferedConn returns a Transport that reads from conn through a buffer.
+// go3270 reads the client's responses one byte at a time, which costs a
+// system call per byte on an unbuffered connection; with a buffer, most
+// reads are served from memory. Writes and SetReadDeadline() go directly to
+// conn. Use the returned Transport for all reads from the connection after
+// wrapping it, since data already read into the buffer is not available
+// from conn.
+//
+// Read deadlines apply when the buffer is empty and data must be read from
+// conn: data already buffered is returned even after the deadline has
+// passed.
+func NewBufferedConn(conn Transport) Transport {
+	return &bufferedConn{Transport: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,103 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// trickleTransport is a Transport that accepts at most one byte per Write.
+type trickleTransport struct {
+	written bytes.Buffer
+}
+
+func (t *trickleTransport) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (t *trickleTransport) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return t.written.Write(b[:1])
+}
+
+func (t *trickleTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func TestWriteAllShortWrites(t *testing.T) {
+	var conn trickleTransport
+	data := []byte{0xf5, 0xc3, 0x11, 0x40, 0x40, 0x13, 0xff, 0xef}
+
+	if err := writeAll(&conn, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(conn.written.Bytes(), data) {
+		t.Errorf("expected %x to be written, got %x", data,
+			conn.written.Bytes())
+	}
+}
+
+// countingTransport is a Transport that repeatedly returns a canned client
+// response, at most one response per Read, and counts the Read calls.
+type countingTransport struct {
+	response []byte
+	pending  []byte
+	reads    int
+}
+
+func (t *countingTransport) Read(b []byte) (int, error) {
+	t.reads++
+	if len(t.pending) == 0 {
+		t.pending = t.response
+	}
+	n := copy(b, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+func (t *countingTransport) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (t *countingTransport) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func benchmarkReadResponse(b *testing.B, buffered bool) {
+	// Enter with two 20-character fields
+	response := []byte{0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1}
+	response = append(response, bytes.Repeat([]byte{0xc1}, 20)...)
+	response = append(response, 0x11, 0xc1, 0xd1)
+	response = append(response, bytes.Repeat([]byte{0xc2}, 20)...)
+	response = append(response, iac, eor)
+	fm := fieldmap{1: "a", 81: "b"}
+
+	counter := &countingTransport{response: response}
+	var conn Transport = counter
+	if buffered {
+		conn = NewBufferedConn(counter)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := readResponse(conn, fm, ScreenOpts{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(counter.reads)/float64(b.N), "reads/op")
+}
+
+func BenchmarkReadResponseUnbuffered(b *testing.B) {
+	benchmarkReadResponse(b, false)
+}
+
+func BenchmarkReadResponseBuffered(b *testing.B) {
+	benchmarkReadResponse(b, true)
+}
@@ -13,16 +13,46 @@ import (
 // Disable debugging by setting it to nil (the default value).
 var Debug io.Writer
 
+// Set DebugDatastream to true, in addition to setting Debug, to also write
+// each outbound datastream to Debug in an annotated form that names the
+// 3270 orders and shows field attributes and contents, rather than only as
+// raw hex.
+var DebugDatastream bool
+
 // debugf will print to the Debug io.Writer if it isn't nil.
 func debugf(format string, a ...interface{}) {
+	debugPrefixf("", format, a...)
+}
+
+// debugPrefixf is debugf with the ScreenOpts.LogPrefix of the operation the
+// message is about, which may be empty.
+func debugPrefixf(prefix, format string, a ...interface{}) {
 	if Debug == nil {
 		return
 	}
 
-	fmt.Fprintf(Debug, "dbg: ")
+	if prefix != "" {
+		fmt.Fprintf(Debug, "dbg: %s ", prefix)
+	} else {
+		fmt.Fprintf(Debug, "dbg: ")
+	}
 	fmt.Fprintf(Debug, format, a...)
 }
 
+// debugConnf is debugf for a message about a read from or write to c,
+// prefixed with the LogPrefix c carries, if any (see readConn).
+func debugConnf(c Transport, format string, a ...interface{}) {
+	debugPrefixf(logPrefix(c), format, a...)
+}
+
+// logPrefix returns the LogPrefix carried by c, or "" if there is none.
+func logPrefix(c Transport) string {
+	if rc, ok := c.(*readConn); ok {
+		return rc.prefix
+	}
+	return ""
+}
+
 // codes are the 3270 control character I/O codes, pre-computed as provided
 // at http://www.tommysprinkle.com/mvs/P3270/iocodes.htm
 var codes = []byte{0x40, 0xc1, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7, 0xc8,
@@ -112,6 +142,10 @@ func AIDtoString(aid AID) string {
 		return "PF23"
 	case AIDPF24:
 		return "PF24"
+	case AIDSelectorPen:
+		return "Selector Pen"
+	case AIDTrigger:
+		return "Trigger"
 	default:
 		return "[unknown]"
 	}
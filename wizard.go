@@ -0,0 +1,112 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"errors"
+)
+
+// ErrWizardCancelled is returned by Wizard.Run() when the user presses the
+// wizard's cancel key.
+var ErrWizardCancelled = errors.New("go3270: wizard cancelled")
+
+// WizardStep is one screen of a Wizard.
+type WizardStep struct {
+	// Screen and Rules are displayed and enforced as with HandleScreen().
+	Screen Screen
+	Rules  Rules
+
+	// Validate, if not nil, is called with all of the values collected so
+	// far, including this step's, once the step's Rules are satisfied. If
+	// it returns a non-empty message, the step is displayed again with the
+	// message in the wizard's ErrorField. This allows checks that span
+	// several fields.
+	Validate func(values map[string]string) string
+}
+
+// Wizard is a multi-step data entry flow: a sequence of screens that the
+// user moves forward through with the next key and back through with the
+// back key, with the values entered on every screen collected together.
+// The user may leave the wizard at any point with the cancel key.
+type Wizard struct {
+	Steps []WizardStep
+
+	// NextKey validates the current step and moves to the next one, or
+	// completes the wizard on the last step. The default is Enter.
+	NextKey AID
+
+	// BackKey returns to the previous step without validating the current
+	// one; the values the user entered are kept. It has no effect on the
+	// first step. The default is PF12, the CUA Cancel key.
+	BackKey AID
+
+	// CancelKey leaves the wizard. The default is PF3, the CUA Exit key.
+	CancelKey AID
+
+	// ErrorField is the name of the field on each step's screen that error
+	// messages are written to.
+	ErrorField string
+
+	// Opts are the options used to display each step.
+	Opts HandleOpts
+}
+
+// Run displays the wizard's steps on conn, starting from the first step
+// with the initial values (which may be nil), until the user completes the
+// last step or cancels. It returns all of the values collected, or
+// ErrWizardCancelled if the user pressed the cancel key.
+func (w *Wizard) Run(conn Transport, values map[string]string) (
+	map[string]string, error) {
+
+	nextKey, backKey, cancelKey := w.NextKey, w.BackKey, w.CancelKey
+	if nextKey == 0 {
+		nextKey = AIDEnter
+	}
+	if backKey == 0 {
+		backKey = AIDPF12
+	}
+	if cancelKey == 0 {
+		cancelKey = AIDPF3
+	}
+
+	collected := make(map[string]string)
+	for k, v := range values {
+		collected[k] = v
+	}
+
+	step := 0
+	for step < len(w.Steps) {
+		s := w.Steps[step]
+		resp, err := HandleScreenOpts(s.Screen, s.Rules, collected,
+			[]AID{nextKey}, []AID{backKey, cancelKey}, w.ErrorField, conn,
+			w.Opts)
+		if err != nil {
+			return nil, err
+		}
+		delete(collected, w.ErrorField)
+
+		switch resp.AID {
+		case cancelKey:
+			return nil, ErrWizardCancelled
+		case backKey:
+			collected = mergeFieldValues(collected, resp.Values)
+			if step > 0 {
+				step--
+			}
+			continue
+		}
+
+		collected = mergeFieldValues(collected, resp.Values)
+		if s.Validate != nil {
+			if msg := s.Validate(collected); msg != "" {
+				collected[w.ErrorField] = msg
+				continue
+			}
+		}
+		step++
+	}
+
+	return collected, nil
+}
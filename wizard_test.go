@@ -0,0 +1,52 @@
+// This file is part of https://github.com/racingmars/go3270/
+// Copyright 2020 by Matthew R. Wilson, licensed under the MIT license. See
+// LICENSE in the project root for license information.
+
+package go3270
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWizard(t *testing.T) {
+	w := Wizard{
+		Steps: []WizardStep{
+			{Screen: Screen{
+				{Row: 0, Col: 0, Name: "first", Write: true},
+				{Row: 0, Col: 10},
+				{Row: 1, Col: 0, Name: "err"},
+			}},
+			{Screen: Screen{
+				{Row: 0, Col: 0, Name: "second", Write: true},
+				{Row: 0, Col: 10},
+				{Row: 1, Col: 0, Name: "err"},
+			}},
+		},
+		ErrorField: "err",
+	}
+
+	// Enter "A" on step 1, PF12 back from step 2, Enter on step 1 (keeping
+	// "A"), then Enter "B" on step 2.
+	conn := &recordingTransport{in: bytes.NewReader([]byte{
+		0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xff, 0xef,
+		0x7c, 0x40, 0xc1, 0xff, 0xef,
+		0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc1, 0xff, 0xef,
+		0x7d, 0x40, 0xc1, 0x11, 0x40, 0xc1, 0xc2, 0xff, 0xef,
+	})}
+
+	values, err := w.Run(conn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["first"] != "A" || values["second"] != "B" {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	// PF3 cancels
+	conn = &recordingTransport{in: bytes.NewReader([]byte{
+		0xf3, 0x40, 0xc1, 0xff, 0xef})}
+	if _, err := w.Run(conn, nil); err != ErrWizardCancelled {
+		t.Errorf("expected ErrWizardCancelled, got %v", err)
+	}
+}